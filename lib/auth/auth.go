@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// APIKeyHeader carry a static API key
+	APIKeyHeader = "X-API-Key"
+
+	// ClientIDBaggageKey carry the authenticated client to downstream services
+	ClientIDBaggageKey = "ClientID"
+
+	authMethodKey = attribute.Key("auth.method")
+)
+
+var errMissingCredentials = errors.New("missing credentials")
+
+type clientIDKey struct{}
+
+// ClientID return the client authenticated by Middleware
+func ClientID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(clientIDKey{}).(string)
+	return id, ok
+}
+
+type rejectionKey struct{}
+
+// TrackRejection return a context in which Middleware record rejecting the credentials of the request,
+// and a func reporting whether it did once the request is served. A 401 from anywhere else is not a rejection
+func TrackRejection(ctx context.Context) (context.Context, func() bool) {
+	rejected := new(bool)
+	return context.WithValue(ctx, rejectionKey{}, rejected), func() bool { return *rejected }
+}
+
+// Authenticator accept static API keys and HMAC signed bearer tokens
+type Authenticator struct {
+	// apiKeys map the sha256 of each key to its client ID, so keys are not kept in clear in memory
+	apiKeys map[[sha256.Size]byte]string
+	secret  []byte
+}
+
+// New return an authenticator for apiKeys, client IDs by key, and bearer tokens signed with secret.
+// Either may be empty to disable that method
+func New(apiKeys map[string]string, secret []byte) *Authenticator {
+	a := &Authenticator{
+		apiKeys: make(map[[sha256.Size]byte]string, len(apiKeys)),
+		secret:  secret,
+	}
+	for key, clientID := range apiKeys {
+		a.apiKeys[sha256.Sum256([]byte(key))] = clientID
+	}
+	return a
+}
+
+// NewFromEnv load API keys from AUTH_API_KEYS and the file named by AUTH_API_KEYS_FILE,
+// both holding client_id:key pairs, and the token secret from AUTH_HMAC_SECRET or the file named by AUTH_HMAC_SECRET_FILE
+func NewFromEnv() (*Authenticator, error) {
+	apiKeys := make(map[string]string)
+	if err := parseAPIKeys(apiKeys, strings.Split(os.Getenv("AUTH_API_KEYS"), ","), "AUTH_API_KEYS"); err != nil {
+		return nil, err
+	}
+	if path := strings.TrimSpace(os.Getenv("AUTH_API_KEYS_FILE")); path != "" {
+		lines, err := readLines(path)
+		if err != nil {
+			return nil, fmt.Errorf("read AUTH_API_KEYS_FILE: %w", err)
+		}
+		if err := parseAPIKeys(apiKeys, lines, path); err != nil {
+			return nil, err
+		}
+	}
+
+	secret := []byte(os.Getenv("AUTH_HMAC_SECRET"))
+	if path := strings.TrimSpace(os.Getenv("AUTH_HMAC_SECRET_FILE")); path != "" {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read AUTH_HMAC_SECRET_FILE: %w", err)
+		}
+		secret = []byte(strings.TrimSpace(string(raw)))
+	}
+
+	return New(apiKeys, secret), nil
+}
+
+// Enabled report whether any credential is configured, without any Middleware let every request through
+func (a *Authenticator) Enabled() bool {
+	return len(a.apiKeys) > 0 || len(a.secret) > 0
+}
+
+// Middleware answer 401 to requests without a valid API key or bearer token. The client ID is stored
+// in the request context, set as enduser.id on the span and added to the baggage sent downstream.
+// A ClientID baggage member sent by the caller is always dropped, even when authentication is disabled
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := withoutClientID(r.Context())
+		if !a.Enabled() {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		span := trace.SpanFromContext(ctx)
+
+		clientID, method, err := a.authenticate(r)
+		if err != nil {
+			span.AddEvent("authentication failed", trace.WithAttributes(
+				authMethodKey.String(method),
+				attribute.String("reason", err.Error()),
+			))
+			if len(a.secret) > 0 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="weather"`)
+			}
+			if rejected, ok := ctx.Value(rejectionKey{}).(*bool); ok {
+				*rejected = true
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		span.SetAttributes(semconv.EnduserIDKey.String(clientID), authMethodKey.String(method))
+
+		ctx = context.WithValue(ctx, clientIDKey{}, clientID)
+		if member, err := baggage.NewMember(ClientIDBaggageKey, clientID); err == nil {
+			if b, err := baggage.FromContext(ctx).SetMember(member); err == nil {
+				ctx = baggage.ContextWithBaggage(ctx, b)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// PropagatedMiddleware trust the ClientID baggage member sent by the caller, for internal services
+// only reachable through a service running Middleware. The client ID is stored in the request context
+// and set as enduser.id on the span for per tenant analysis. It is dropped from the baggage,
+// the third party APIs called by these services must not learn who their clients are
+func PropagatedMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		clientID := baggage.FromContext(ctx).Member(ClientIDBaggageKey).Value()
+		if clientID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		trace.SpanFromContext(ctx).SetAttributes(semconv.EnduserIDKey.String(clientID))
+		ctx = context.WithValue(withoutClientID(ctx), clientIDKey{}, clientID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// withoutClientID drop the ClientID member from the baggage of ctx
+func withoutClientID(ctx context.Context) context.Context {
+	b := baggage.FromContext(ctx)
+	if b.Member(ClientIDBaggageKey).Key() == "" {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b.DeleteMember(ClientIDBaggageKey))
+}
+
+// authenticate return the client ID of the request and the method which identified it
+func (a *Authenticator) authenticate(r *http.Request) (string, string, error) {
+	if key := strings.TrimSpace(r.Header.Get(APIKeyHeader)); key != "" {
+		clientID, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return "", "api_key", errors.New("unknown api key")
+		}
+		return clientID, "api_key", nil
+	}
+
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		const prefix = "Bearer "
+		if len(a.secret) == 0 || len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+			return "", "bearer", errors.New("unsupported authorization")
+		}
+		clientID, err := verifyToken(a.secret, strings.TrimSpace(authorization[len(prefix):]))
+		return clientID, "bearer", err
+	}
+
+	return "", "none", errMissingCredentials
+}
+
+func parseAPIKeys(apiKeys map[string]string, entries []string, source string) error {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		i := strings.IndexByte(entry, ':')
+		if i <= 0 || i == len(entry)-1 {
+			// never echo the entry, it holds a key
+			return fmt.Errorf("invalid api key entry in %s: must be client_id:key", source)
+		}
+		apiKeys[strings.TrimSpace(entry[i+1:])] = strings.TrimSpace(entry[:i])
+	}
+	return nil
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestVerifyToken(t *testing.T) {
+	secret := []byte("s3cr3t")
+	valid, err := SignToken(secret, "globex", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := SignToken(secret, "globex", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clientID, err := verifyToken(secret, valid); err != nil || clientID != "globex" {
+		t.Errorf("valid token = %q, %v, want globex", clientID, err)
+	}
+	if _, err := verifyToken(secret, expired); err != errExpiredToken {
+		t.Errorf("expired token error = %v, want %v", err, errExpiredToken)
+	}
+	if _, err := verifyToken([]byte("other"), valid); err != errBadSignature {
+		t.Errorf("token of another secret error = %v, want %v", err, errBadSignature)
+	}
+	if _, err := verifyToken(secret, valid+"x"); err == nil {
+		t.Error("tampered token accepted")
+	}
+}
+
+func TestMiddlewareDropsCallerClientID(t *testing.T) {
+	spoofed := func() context.Context {
+		member, _ := baggage.NewMember(ClientIDBaggageKey, "spoofed")
+		b, _ := baggage.New(member)
+		return baggage.ContextWithBaggage(context.Background(), b)
+	}
+
+	tests := []struct {
+		name   string
+		auth   *Authenticator
+		apiKey string
+		status int
+		want   string
+	}{
+		{"authentication disabled", New(nil, nil), "", http.StatusOK, ""},
+		{"rejected key", New(map[string]string{"k1": "acme"}, nil), "bad", http.StatusUnauthorized, ""},
+		{"verified key", New(map[string]string{"k1": "acme"}, nil), "k1", http.StatusOK, "acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			called := false
+			handler := tt.auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				got = baggage.FromContext(r.Context()).Member(ClientIDBaggageKey).Value()
+				if id, ok := ClientID(r.Context()); ok && id != got {
+					t.Errorf("ClientID = %q, baggage = %q", id, got)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/forecast/depok", nil).WithContext(spoofed())
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if called && got != tt.want {
+				t.Errorf("ClientID baggage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropagatedMiddlewareKeepsClientIDOutOfBaggage(t *testing.T) {
+	member, _ := baggage.NewMember(ClientIDBaggageKey, "acme")
+	route, _ := baggage.NewMember("FunctionRoute", "/forecast/{city}")
+	b, _ := baggage.New(member, route)
+	ctx := baggage.ContextWithBaggage(context.Background(), b)
+
+	var clientID string
+	var found bool
+	var sent baggage.Baggage
+	handler := PropagatedMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, found = ClientID(r.Context())
+		sent = baggage.FromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/getforecast/owm/depok", nil).WithContext(ctx))
+
+	if !found || clientID != "acme" {
+		t.Errorf("ClientID = %q, %v, want acme", clientID, found)
+	}
+	// the baggage is injected in calls to third party APIs
+	if v := sent.Member(ClientIDBaggageKey).Value(); v != "" {
+		t.Errorf("ClientID baggage = %q, want it dropped", v)
+	}
+	if v := sent.Member("FunctionRoute").Value(); v != "/forecast/{city}" {
+		t.Errorf("FunctionRoute baggage = %q, want it kept", v)
+	}
+}
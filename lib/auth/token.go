@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Bearer tokens are JWTs signed with HS256, so any JWT library can issue them.
+// The client ID is the sub claim, exp is required and nbf honoured when present
+
+var (
+	errMalformedToken = errors.New("malformed token")
+	errBadSignature   = errors.New("invalid token signature")
+	errExpiredToken   = errors.New("token expired")
+	errTokenNotValid  = errors.New("token not valid yet")
+)
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+type tokenClaims struct {
+	Sub string `json:"sub"`
+	Exp int64  `json:"exp"`
+	Nbf int64  `json:"nbf,omitempty"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// SignToken issue a bearer token for clientID valid for ttl
+func SignToken(secret []byte, clientID string, ttl time.Duration) (string, error) {
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(tokenClaims{Sub: clientID, Exp: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	signed := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	return signed + "." + encoding.EncodeToString(sign(secret, signed)), nil
+}
+
+// verifyToken return the client ID of a token signed with secret and valid now
+func verifyToken(secret []byte, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errMalformedToken
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errMalformedToken
+	}
+	if !hmac.Equal(signature, sign(secret, parts[0]+"."+parts[1])) {
+		return "", errBadSignature
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return "", errMalformedToken
+	}
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims.Sub == "" || claims.Exp == 0 {
+		return "", errMalformedToken
+	}
+
+	now := time.Now().Unix()
+	if now >= claims.Exp {
+		return "", errExpiredToken
+	}
+	if claims.Nbf != 0 && now < claims.Nbf {
+		return "", errTokenNotValid
+	}
+	return claims.Sub, nil
+}
+
+func sign(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := encoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size bounded cache evicting the least recently used entry first.
+// It remember when each value was stored and leave deciding what is fresh to the caller
+type LRU struct {
+	maxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key      string
+	value    interface{}
+	storedAt time.Time
+}
+
+// New return a cache holding at most maxEntries values
+func New(maxEntries int) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get return the value stored for key and how long ago it was stored
+func (c *LRU) Get(key string) (interface{}, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, 0, false
+	}
+	c.ll.MoveToFront(el)
+	e := el.Value.(*entry)
+	return e.value, time.Since(e.storedAt), true
+}
+
+// Add store value for key, replacing any previous value,
+// and return how many entries were evicted to make room
+func (c *LRU) Add(key string, value interface{}) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		e := el.Value.(*entry)
+		e.value = value
+		e.storedAt = time.Now()
+		return 0
+	}
+
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: value, storedAt: time.Now()})
+
+	evicted := 0
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		evicted++
+	}
+	return evicted
+}
+
+// Remove drop the value stored for key, if any
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Len return the number of stored values
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *LRU) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
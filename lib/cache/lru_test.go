@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	// a is now more recently used than b
+	if _, _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing")
+	}
+
+	if evicted := c.Add("c", 3); evicted != 1 {
+		t.Errorf("evicted %d entries, want 1", evicted)
+	}
+	if _, _, ok := c.Get("b"); ok {
+		t.Error("b kept, want it evicted as least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, _, ok := c.Get(key); !ok {
+			t.Errorf("%s evicted", key)
+		}
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("len = %d, want 2", n)
+	}
+}
+
+func TestLRUAge(t *testing.T) {
+	c := New(10)
+	c.Add("a", 1)
+	time.Sleep(20 * time.Millisecond)
+
+	value, age, ok := c.Get("a")
+	if !ok || value != 1 {
+		t.Fatalf("Get = %v, %v, want 1", value, ok)
+	}
+	if age < 20*time.Millisecond {
+		t.Errorf("age = %s, want at least 20ms", age)
+	}
+
+	// replacing a value store it anew without evicting anything
+	if evicted := c.Add("a", 2); evicted != 0 {
+		t.Errorf("replace evicted %d entries", evicted)
+	}
+	value, age, _ = c.Get("a")
+	if value != 2 || age >= 20*time.Millisecond {
+		t.Errorf("Get after replace = %v aged %s, want 2 stored just now", value, age)
+	}
+}
+
+func TestLRUUnbounded(t *testing.T) {
+	c := New(0)
+	for i := 0; i < 100; i++ {
+		if evicted := c.Add(string(rune('a'+i)), i); evicted != 0 {
+			t.Fatalf("evicted %d entries without a bound", evicted)
+		}
+	}
+	c.Remove("a")
+	if n := c.Len(); n != 99 {
+		t.Errorf("len = %d, want 99", n)
+	}
+}
@@ -0,0 +1,184 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/logging"
+	"weather/lib/metrics"
+)
+
+// ErrCircuitOpen is returned without sending the request while the breaker of its host is open,
+// handlers should answer 503 Service Unavailable
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerPolicy decide when the breaker of a host stop sending requests.
+// FailureThreshold consecutive failed attempts open it, after CoolDown one trial request
+// is let through and close it again on success. A FailureThreshold of 0 disable the breaker
+type BreakerPolicy struct {
+	FailureThreshold int
+	CoolDown         time.Duration
+}
+
+// DefaultBreakerPolicy is used by clients not given their own policy
+var DefaultBreakerPolicy = BreakerPolicy{
+	FailureThreshold: 5,
+	CoolDown:         30 * time.Second,
+}
+
+type breakerState string
+
+const (
+	stateClosed   breakerState = "closed"
+	stateOpen     breakerState = "open"
+	stateHalfOpen breakerState = "half-open"
+
+	breakerStateKey = attribute.Key("circuit_breaker.state")
+)
+
+// breakers hold one breaker per host, created on first use
+type breakers struct {
+	policy BreakerPolicy
+
+	mu     sync.Mutex
+	byHost map[string]*breaker
+}
+
+func newBreakers(policy BreakerPolicy) *breakers {
+	return &breakers{
+		policy: policy,
+		byHost: make(map[string]*breaker),
+	}
+}
+
+func (bs *breakers) get(host string) *breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	b, ok := bs.byHost[host]
+	if !ok {
+		b = &breaker{host: host, policy: bs.policy, state: stateClosed}
+		bs.byHost[host] = b
+	}
+	return b
+}
+
+type breaker struct {
+	host   string
+	policy BreakerPolicy
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// generation change on every transition, outcomes of requests allowed before it are stale
+	generation uint64
+	// trial is set while the single half-open request is in flight
+	trial bool
+}
+
+// ticket identify an allowed request to done
+type ticket struct {
+	generation uint64
+	trial      bool
+}
+
+// allow return ErrCircuitOpen when the request must not be sent,
+// otherwise the ticket to hand to done with its outcome
+func (b *breaker) allow(ctx context.Context) (ticket, error) {
+	if b.policy.FailureThreshold <= 0 {
+		return ticket{}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.policy.CoolDown {
+			return ticket{}, b.reject(ctx)
+		}
+		b.transition(ctx, stateHalfOpen)
+		b.trial = true
+		return ticket{generation: b.generation, trial: true}, nil
+	case stateHalfOpen:
+		if b.trial {
+			return ticket{}, b.reject(ctx)
+		}
+		b.trial = true
+		return ticket{generation: b.generation, trial: true}, nil
+	}
+	return ticket{generation: b.generation}, nil
+}
+
+// done record the outcome of the request allowed with t
+func (b *breaker) done(ctx context.Context, t ticket, resp *http.Response, err error) {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t.trial {
+		b.trial = false
+	}
+
+	// the request was allowed before the last transition, its outcome says nothing of the current state
+	if t.generation != b.generation {
+		return
+	}
+
+	// the caller gave up, the host is not to blame
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	if err == nil && resp.StatusCode < http.StatusInternalServerError {
+		b.failures = 0
+		if b.state != stateClosed {
+			b.transition(ctx, stateClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || (b.state == stateClosed && b.failures >= b.policy.FailureThreshold) {
+		b.openedAt = time.Now()
+		b.transition(ctx, stateOpen)
+	}
+}
+
+func (b *breaker) reject(ctx context.Context) error {
+	trace.SpanFromContext(ctx).AddEvent("circuit_breaker.rejected", trace.WithAttributes(
+		semconv.HTTPHostKey.String(b.host),
+		breakerStateKey.String(string(b.state)),
+	))
+	return fmt.Errorf("%s: %w", b.host, ErrCircuitOpen)
+}
+
+func (b *breaker) transition(ctx context.Context, to breakerState) {
+	from := b.state
+	b.state = to
+	b.generation++
+
+	trace.SpanFromContext(ctx).AddEvent("circuit_breaker.state_change", trace.WithAttributes(
+		semconv.HTTPHostKey.String(b.host),
+		attribute.String("circuit_breaker.from", string(from)),
+		breakerStateKey.String(string(to)),
+	))
+	metrics.RecordBreakerTransition(ctx, b.host, string(to))
+	logging.Warn(ctx, "circuit breaker state changed", logging.Fields{
+		"host": b.host,
+		"from": string(from),
+		"to":   string(to),
+	})
+}
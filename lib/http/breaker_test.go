@@ -0,0 +1,70 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreakerIgnoresStaleOutcomes(t *testing.T) {
+	ctx := context.Background()
+	b := newBreakers(BreakerPolicy{FailureThreshold: 1, CoolDown: time.Millisecond}).get("owm:8082")
+	ok := &http.Response{StatusCode: http.StatusOK}
+	failed := errors.New("connection refused")
+
+	// a slow request allowed while closed, still in flight when the breaker opens
+	slow, err := b.allow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opener, _ := b.allow(ctx)
+	b.done(ctx, opener, nil, failed)
+	if b.state != stateOpen {
+		t.Fatalf("state = %s, want %s", b.state, stateOpen)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	trial, err := b.allow(ctx)
+	if err != nil || !trial.trial {
+		t.Fatalf("allow after cool down = %+v, %v, want the trial", trial, err)
+	}
+
+	// the slow request ends during half-open, it must neither close the breaker nor free the trial
+	b.done(ctx, slow, ok, nil)
+	if b.state != stateHalfOpen {
+		t.Errorf("state after stale success = %s, want %s", b.state, stateHalfOpen)
+	}
+	if _, err := b.allow(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second request during half-open = %v, want ErrCircuitOpen", err)
+	}
+
+	b.done(ctx, trial, ok, nil)
+	if b.state != stateClosed {
+		t.Errorf("state after trial success = %s, want %s", b.state, stateClosed)
+	}
+}
+
+func TestBreakerTrialFailureReopens(t *testing.T) {
+	ctx := context.Background()
+	b := newBreakers(BreakerPolicy{FailureThreshold: 1, CoolDown: 50 * time.Millisecond}).get("owm:8082")
+	failed := errors.New("connection refused")
+
+	first, _ := b.allow(ctx)
+	b.done(ctx, first, nil, failed)
+
+	time.Sleep(60 * time.Millisecond)
+	trial, err := b.allow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.done(ctx, trial, &http.Response{StatusCode: http.StatusBadGateway}, nil)
+	if b.state != stateOpen {
+		t.Errorf("state after failed trial = %s, want %s", b.state, stateOpen)
+	}
+	if _, err := b.allow(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("request after failed trial = %v, want ErrCircuitOpen", err)
+	}
+}
@@ -2,82 +2,315 @@ package xhttp
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
-	"os"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/ernesto-jimenez/httplogger"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/logging"
+	"weather/lib/metrics"
+	"weather/lib/redact"
+	"weather/lib/tracing"
 )
 
 /*
 httpLogger log http request response
 */
-type httpLogger struct {
-	log *log.Logger
-}
+type httpLogger struct{}
 
 func newLogger() *httpLogger {
-	return &httpLogger{
-		log: log.New(os.Stderr, "log - ", log.LstdFlags),
-	}
+	return &httpLogger{}
 }
 
 func (l *httpLogger) LogRequest(req *http.Request) {
-	l.log.Printf(
-		"Request %s %s\n",
-		req.Method,
-		req.URL.String(),
-	)
-	l.log.Printf(
-		"Request %+q %s",
-		req.Header["User-Agent"],
-		req.URL.String(),
-	)
+	logging.Info(req.Context(), "client request", logging.Fields{
+		"http_method": req.Method,
+		"http_url":    redact.URL(req.URL),
+		"user_agent":  req.UserAgent(),
+	})
 }
 
 func (l *httpLogger) LogResponse(req *http.Request, res *http.Response, err error, duration time.Duration) {
-	duration /= time.Millisecond
+	fields := logging.Fields{
+		"http_method": req.Method,
+		"http_url":    redact.URL(req.URL),
+		"duration_ms": float64(duration) / float64(time.Millisecond),
+	}
 	if err != nil {
-		l.log.Println(err)
-	} else {
-		l.log.Printf(
-			"Response method=%s status=%d durationMs=%d %s",
-			req.Method,
-			res.StatusCode,
-			duration,
-			req.URL.String(),
-		)
+		fields["error"] = err
+		logging.Error(req.Context(), "client request failed", fields)
+		return
 	}
+
+	fields["http_status"] = res.StatusCode
+	logging.Info(req.Context(), "client response", fields)
 }
 
-func Do(ctx context.Context, req *http.Request, tracer trace.Tracer) (*http.Response, error) {
+// clientAttributes describe an outgoing request following the OpenTelemetry HTTP client conventions
+func clientAttributes(req *http.Request) []attribute.KeyValue {
+	// never record credentials of the URL
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = redact.Query(u.RawQuery)
+
+	attrs := []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(req.Method),
+		semconv.HTTPURLKey.String(u.String()),
+		semconv.HTTPSchemeKey.String(u.Scheme),
+		semconv.HTTPHostKey.String(u.Host),
+		semconv.NetPeerNameKey.String(u.Hostname()),
+	}
+
+	port := u.Port()
+	if port == "" && u.Scheme == "https" {
+		port = "443"
+	} else if port == "" {
+		port = "80"
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(p))
+	}
+
+	return attrs
+}
+
+const (
+	defaultTimeout = 10 * time.Second
+
+	attemptKey      = attribute.Key("http.attempt")
+	attemptCountKey = attribute.Key("http.attempt_count")
+)
 
-	spanCtx, span := tracer.Start(ctx, req.RequestURI, trace.WithSpanKind(trace.SpanKindClient))
+// Client send traced, logged and measured HTTP requests, retrying transient failures
+type Client struct {
+	httpClient *http.Client
+	retry      RetryPolicy
+	breakers   *breakers
+}
+
+// Option configure a Client
+type Option func(*clientConfig)
+
+type clientConfig struct {
+	timeout   time.Duration
+	transport http.RoundTripper
+	retry     RetryPolicy
+	breaker   BreakerPolicy
+}
+
+// WithTimeout bound every attempt of the client, including reading the body. Zero means no limit
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *clientConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithTransport replace the shared transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(cfg *clientConfig) {
+		cfg.transport = transport
+	}
+}
+
+// WithRetry replace DefaultRetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	return func(cfg *clientConfig) {
+		cfg.retry = policy
+	}
+}
+
+// WithBreaker replace DefaultBreakerPolicy
+func WithBreaker(policy BreakerPolicy) Option {
+	return func(cfg *clientConfig) {
+		cfg.breaker = policy
+	}
+}
+
+// NewClient return a client over the shared transport with a 10s timeout,
+// DefaultRetryPolicy and DefaultBreakerPolicy unless configured otherwise
+func NewClient(opts ...Option) *Client {
+	cfg := clientConfig{
+		timeout:   defaultTimeout,
+		transport: sharedTransport,
+		retry:     DefaultRetryPolicy,
+		breaker:   DefaultBreakerPolicy,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Client{
+		httpClient: &http.Client{
+			Timeout:   cfg.timeout,
+			Transport: httplogger.NewLoggedTransport(cfg.transport, newLogger()),
+		},
+		retry:    cfg.retry,
+		breakers: newBreakers(cfg.breaker),
+	}
+}
+
+// Do send req, again after a transient failure when the method is idempotent.
+// Every attempt get its own CLIENT span under one span for the whole call.
+// While the breaker of the host is open Do fail fast with ErrCircuitOpen.
+// The deadline of ctx bound all attempts together, on top of the per attempt client timeout.
+// A gate set with WithAttemptGate must let each attempt through
+func (c *Client) Do(ctx context.Context, req *http.Request, tracer trace.Tracer) (*http.Response, error) {
+
+	spanCtx, span := tracer.Start(
+		ctx,
+		"HTTP "+req.Method,
+		trace.WithAttributes(clientAttributes(req)...),
+	)
 
 	defer span.End()
 
-	span.SetAttributes(attribute.Key("http_client_call").String("inside xhttp Do"))
+	retryable := c.retry.retryable(req)
+	breaker := c.breakers.get(req.URL.Host)
+
+	gate := attemptGate(ctx)
+	if gate != nil {
+		if err := gate(spanCtx); err != nil {
+			return nil, tracing.RecordError(span, err)
+		}
+	}
+
+	attempt := 0
+	var resp *http.Response
+	var err error
+	for {
+		var t ticket
+		if t, err = breaker.allow(spanCtx); err != nil {
+			break
+		}
+
+		attempt++
+		resp, err = c.attempt(spanCtx, req, attempt, tracer)
+		breaker.done(spanCtx, t, resp, err)
+
+		if !retryable || attempt >= c.retry.MaxAttempts {
+			break
+		}
+		reason := retryReason(resp, err)
+		if reason == "" {
+			break
+		}
+
+		delay, ok := RetryAfter(resp)
+		if ok && delay > c.retry.MaxRetryAfter {
+			// waiting so long would hang the caller, let it see the hint instead
+			span.AddEvent("retry abandoned", trace.WithAttributes(
+				attemptKey.Int(attempt),
+				attribute.Float64("retry_after_ms", float64(delay)/float64(time.Millisecond)),
+			))
+			break
+		}
+		if !ok {
+			delay = c.retry.backoff(attempt)
+		}
+		if !fitsDeadline(spanCtx, delay) {
+			break
+		}
+		if gate != nil {
+			if gateErr := gate(spanCtx); gateErr != nil {
+				// the last response tell the caller more than the gate refusing a retry
+				span.AddEvent("retry abandoned", trace.WithAttributes(
+					attemptKey.Int(attempt),
+					attribute.String("reason", gateErr.Error()),
+				))
+				break
+			}
+		}
+
+		span.AddEvent("retry", trace.WithAttributes(
+			attemptKey.Int(attempt),
+			attribute.String("reason", reason),
+			attribute.Float64("delay_ms", float64(delay)/float64(time.Millisecond)),
+		))
+		if sleepErr := sleep(spanCtx, delay); sleepErr != nil {
+			break
+		}
 
-	client := &http.Client{
-		Timeout:   time.Second * 10,
-		Transport: httplogger.NewLoggedTransport(http.DefaultTransport, newLogger()),
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				break
+			}
+			req.Body = body
+		}
+		discard(resp)
 	}
+	span.SetAttributes(attemptCountKey.Int(attempt))
 
-	otelCtx, req := otelhttptrace.W3C(spanCtx, req)
-	otelhttptrace.Inject(otelCtx, req)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+
+	// the response is still returned, callers decide what a failed status means for them
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+		return resp, nil
+	}
+
+	span.SetStatus(codes.Ok, "requestHTTPSuccessfull")
+	return resp, nil
+
+}
 
-	resp, err := client.Do(req)
+// attempt send req once in its own CLIENT span
+func (c *Client) attempt(ctx context.Context, req *http.Request, attempt int, tracer trace.Tracer) (*http.Response, error) {
+	spanCtx, span := tracer.Start(
+		ctx,
+		"HTTP "+req.Method+" attempt",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(clientAttributes(req)...),
+		trace.WithAttributes(attemptKey.Int(attempt)),
+	)
+	defer span.End()
+
+	otelCtx, req := withClientTrace(spanCtx, req)
+	otelhttptrace.Inject(otelCtx, req, otelhttptrace.WithPropagators(otel.GetTextMapPropagator()))
+
+	done := metrics.StartClientRequest(spanCtx, req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		done(0)
+		// the error message quote the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redact.URLString(urlErr.URL)
+		}
+		return nil, tracing.RecordError(span, err)
 	}
+	done(resp.StatusCode)
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(ctx).SetStatus(codes.Ok, "requestHTTPSuccessfull")
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+	}
 	return resp, nil
+}
+
+// withClientTrace attach the otelhttptrace hooks to req,
+// redacting sensitive headers before they become span attributes
+func withClientTrace(ctx context.Context, req *http.Request) (context.Context, *http.Request) {
+	ct := otelhttptrace.NewClientTrace(ctx)
+	wroteHeaderField := ct.WroteHeaderField
+	ct.WroteHeaderField = func(key string, values []string) {
+		wroteHeaderField(key, redact.Header(key, values))
+	}
 
+	ctx = httptrace.WithClientTrace(ctx, ct)
+	return ctx, req.WithContext(ctx)
 }
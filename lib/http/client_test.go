@@ -0,0 +1,204 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDoSpans(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first attempt so the call has two attempt spans
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(recorder)).Tracer("test")
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/data/2.5/weather?q=london&appid=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := NewClient().Do(context.Background(), req, tracer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	u, _ := url.Parse(srv.URL)
+	var attempts int
+	for _, span := range recorder.GetSpans() {
+		attrs := attributes(span.Attributes)
+
+		for _, kv := range span.Attributes {
+			if strings.Contains(kv.Value.Emit(), "secret") {
+				t.Errorf("span %q attribute %s leaks the api key: %s", span.Name, kv.Key, kv.Value.Emit())
+			}
+		}
+		if got := attrs[semconv.HTTPURLKey].AsString(); !strings.Contains(got, "appid=REDACTED") {
+			t.Errorf("span %q %s = %q, want appid redacted", span.Name, semconv.HTTPURLKey, got)
+		}
+
+		switch span.Name {
+		case "HTTP GET attempt":
+			attempts++
+			if span.SpanKind != trace.SpanKindClient {
+				t.Errorf("attempt kind = %v, want %v", span.SpanKind, trace.SpanKindClient)
+			}
+			if got := attrs[semconv.HTTPMethodKey].AsString(); got != http.MethodGet {
+				t.Errorf("attempt %s = %q, want GET", semconv.HTTPMethodKey, got)
+			}
+			if got := attrs[semconv.NetPeerNameKey].AsString(); got != u.Hostname() {
+				t.Errorf("attempt %s = %q, want %q", semconv.NetPeerNameKey, got, u.Hostname())
+			}
+			if _, ok := attrs[semconv.HTTPStatusCodeKey]; !ok {
+				t.Errorf("attempt has no %s", semconv.HTTPStatusCodeKey)
+			}
+		case "HTTP GET":
+			if span.SpanKind != trace.SpanKindInternal {
+				t.Errorf("call kind = %v, want %v", span.SpanKind, trace.SpanKindInternal)
+			}
+			if got := attrs[attemptCountKey].AsInt64(); got != 2 {
+				t.Errorf("call %s = %d, want 2", attemptCountKey, got)
+			}
+			if got := attrs[semconv.HTTPStatusCodeKey].AsInt64(); got != http.StatusOK {
+				t.Errorf("call %s = %d, want 200", semconv.HTTPStatusCodeKey, got)
+			}
+		default:
+			t.Errorf("unexpected span %q", span.Name)
+		}
+	}
+	if attempts != 2 {
+		t.Errorf("got %d attempt spans, want 2", attempts)
+	}
+}
+
+func TestDoRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		attempts   int32
+	}{
+		{"short hint is waited for", "0", 3},
+		{"long hint is returned at once", "60", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.Header().Set("Retry-After", tt.retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			resp, err := NewClient().Do(context.Background(), req, trace.NewNoopTracerProvider().Tracer("test"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("status = %d, want 429", resp.StatusCode)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.attempts {
+				t.Errorf("got %d attempts, want %d", got, tt.attempts)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Do took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestDoAttemptGate(t *testing.T) {
+	gateErr := errors.New("no token left")
+
+	tests := []struct {
+		name     string
+		allowed  int
+		attempts int32
+		wantErr  bool
+	}{
+		{"every attempt allowed", 3, 3, false},
+		{"retry refused", 1, 1, false},
+		{"first attempt refused", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer srv.Close()
+
+			gated := 0
+			ctx := WithAttemptGate(context.Background(), func(context.Context) error {
+				gated++
+				if gated > tt.allowed {
+					return gateErr
+				}
+				return nil
+			})
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := NewClient().Do(ctx, req, trace.NewNoopTracerProvider().Tracer("test"))
+			if tt.wantErr {
+				if !errors.Is(err, gateErr) {
+					t.Errorf("error = %v, want %v", err, gateErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				// a refused retry return the last response
+				if resp.StatusCode != http.StatusTooManyRequests {
+					t.Errorf("status = %d, want 429", resp.StatusCode)
+				}
+			}
+
+			if got := atomic.LoadInt32(&calls); got != tt.attempts {
+				t.Errorf("got %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func attributes(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
@@ -0,0 +1,73 @@
+package xhttp
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	timeoutEnv          = "HTTP_CLIENT_TIMEOUT"
+	maxAttemptsEnv      = "HTTP_CLIENT_RETRY_MAX_ATTEMPTS"
+	initialBackoffEnv   = "HTTP_CLIENT_RETRY_INITIAL_BACKOFF"
+	maxBackoffEnv       = "HTTP_CLIENT_RETRY_MAX_BACKOFF"
+	maxRetryAfterEnv    = "HTTP_CLIENT_RETRY_MAX_RETRY_AFTER"
+	failureThresholdEnv = "HTTP_CLIENT_BREAKER_FAILURE_THRESHOLD"
+	coolDownEnv         = "HTTP_CLIENT_BREAKER_COOLDOWN"
+)
+
+// NewClientFromEnv build a client configured by the HTTP_CLIENT_* variables,
+// unset variables keep the NewClient defaults
+func NewClientFromEnv() (*Client, error) {
+	timeout, err := durationFromEnv(timeoutEnv, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := DefaultRetryPolicy
+	if v := strings.TrimSpace(os.Getenv(maxAttemptsEnv)); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("invalid %s %q: must be a positive integer", maxAttemptsEnv, v)
+		}
+		policy.MaxAttempts = attempts
+	}
+	if policy.InitialBackoff, err = durationFromEnv(initialBackoffEnv, policy.InitialBackoff); err != nil {
+		return nil, err
+	}
+	if policy.MaxBackoff, err = durationFromEnv(maxBackoffEnv, policy.MaxBackoff); err != nil {
+		return nil, err
+	}
+	if policy.MaxRetryAfter, err = durationFromEnv(maxRetryAfterEnv, policy.MaxRetryAfter); err != nil {
+		return nil, err
+	}
+
+	breaker := DefaultBreakerPolicy
+	if v := strings.TrimSpace(os.Getenv(failureThresholdEnv)); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid %s %q: must be a non negative integer", failureThresholdEnv, v)
+		}
+		breaker.FailureThreshold = threshold
+	}
+	if breaker.CoolDown, err = durationFromEnv(coolDownEnv, breaker.CoolDown); err != nil {
+		return nil, err
+	}
+
+	return NewClient(WithTimeout(timeout), WithRetry(policy), WithBreaker(breaker)), nil
+}
+
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non negative duration such as 5s", name, v)
+	}
+	return d, nil
+}
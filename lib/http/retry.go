@@ -0,0 +1,151 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decide how many times and how often an idempotent request is sent again
+// after a transient failure. MaxAttempts of 1 disable retries.
+// A Retry-After hint longer than MaxRetryAfter end the retries, the response is returned at once
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxRetryAfter  time.Duration
+}
+
+// DefaultRetryPolicy is used by clients not given their own policy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	MaxRetryAfter:  5 * time.Second,
+}
+
+type attemptGateKey struct{}
+
+// WithAttemptGate return a context in which Do call gate before sending each attempt of a request,
+// retries included, such as a client side rate limiter taking one token per call.
+// An error of gate on the first attempt is returned by Do, on a retry the last response is returned instead
+func WithAttemptGate(ctx context.Context, gate func(context.Context) error) context.Context {
+	return context.WithValue(ctx, attemptGateKey{}, gate)
+}
+
+func attemptGate(ctx context.Context) func(context.Context) error {
+	gate, _ := ctx.Value(attemptGateKey{}).(func(context.Context) error)
+	return gate
+}
+
+// maxDrain bound how much of a discarded response is read so its connection can be reused
+const maxDrain = 4096
+
+// retryable report whether req may be sent more than once
+func (p RetryPolicy) retryable(req *http.Request) bool {
+	if p.MaxAttempts <= 1 {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+
+	// a consumed body can only be sent again if it can be rebuilt
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// backoff return the delay before the attempt following attempt,
+// full jitter over an exponential window capped at MaxBackoff
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	window := p.InitialBackoff
+	for i := 1; i < attempt && window < p.MaxBackoff; i++ {
+		window *= 2
+	}
+	if window > p.MaxBackoff {
+		window = p.MaxBackoff
+	}
+	if window <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(window)))
+}
+
+// retryReason return why the outcome of an attempt is worth retrying, empty when it is not
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		switch {
+		case errors.Is(err, syscall.ECONNRESET):
+			return "connection reset"
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return "connection closed"
+		}
+		return ""
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return http.StatusText(resp.StatusCode)
+	}
+	return ""
+}
+
+// RetryAfter parse the Retry-After header of resp, given either in seconds or as an HTTP date
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// fitsDeadline report whether waiting delay still leaves ctx time to send a request
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Now().Add(delay).Before(deadline)
+}
+
+// sleep wait for delay unless ctx is done first
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// discard release a response which will not be returned to the caller
+func discard(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrain))
+	resp.Body.Close()
+}
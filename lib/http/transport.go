@@ -0,0 +1,39 @@
+package xhttp
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	dialTimeout           = 5 * time.Second
+	dialKeepAlive         = 30 * time.Second
+	maxIdleConns          = 100
+	maxIdleConnsPerHost   = 20
+	idleConnTimeout       = 90 * time.Second
+	tlsHandshakeTimeout   = 10 * time.Second
+	expectContinueTimeout = 1 * time.Second
+)
+
+// sharedTransport is reused by every Client not given its own transport,
+// so connections to the same host are pooled across clients
+var sharedTransport = NewTransport()
+
+// NewTransport return a transport tuned for many requests to a few hosts,
+// keeping connections alive and negotiating HTTP/2 when the server supports it
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: dialKeepAlive,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ExpectContinueTimeout: expectContinueTimeout,
+	}
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Level of a log line
+type Level string
+
+const (
+	LevelDebug Level = "debug"
+	LevelInfo  Level = "info"
+	LevelWarn  Level = "warn"
+	LevelError Level = "error"
+	LevelFatal Level = "fatal"
+)
+
+// Fields are extra key/value pairs added to a log line
+type Fields map[string]interface{}
+
+// Logger write JSON lines correlated with the span and chi request id found in the context
+type Logger struct {
+	service string
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func New(service string, out io.Writer) *Logger {
+	return &Logger{
+		service: service,
+		out:     out,
+	}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = New("", os.Stderr)
+)
+
+// Init replace the package logger with one tagging lines with service
+func Init(service string) {
+	SetDefault(New(service, os.Stderr))
+}
+
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+func (l *Logger) Log(ctx context.Context, level Level, msg string, fields Fields) {
+	entry := make(map[string]interface{}, len(fields)+7)
+	for k, v := range fields {
+		// errors marshal to an empty object
+		if err, ok := v.(error); ok && err != nil {
+			v = err.Error()
+		}
+		entry[k] = v
+	}
+
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["msg"] = msg
+	if l.service != "" {
+		entry["service"] = l.service
+	}
+
+	if ctx != nil {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			entry["trace_id"] = sc.TraceID().String()
+			entry["span_id"] = sc.SpanID().String()
+		}
+		if reqID := middleware.GetReqID(ctx); reqID != "" {
+			entry["request_id"] = reqID
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line = []byte(fmt.Sprintf(`{"level":%q,"msg":%q,"log_error":%q}`, LevelError, msg, err.Error()))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(line, '\n'))
+}
+
+func (l *Logger) Debug(ctx context.Context, msg string, fields Fields) {
+	l.Log(ctx, LevelDebug, msg, fields)
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, fields Fields) {
+	l.Log(ctx, LevelInfo, msg, fields)
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, fields Fields) {
+	l.Log(ctx, LevelWarn, msg, fields)
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, fields Fields) {
+	l.Log(ctx, LevelError, msg, fields)
+}
+
+// Fatal log then exit the process
+func (l *Logger) Fatal(ctx context.Context, msg string, fields Fields) {
+	l.Log(ctx, LevelFatal, msg, fields)
+	os.Exit(1)
+}
+
+func Debug(ctx context.Context, msg string, fields Fields) {
+	Default().Log(ctx, LevelDebug, msg, fields)
+}
+
+func Info(ctx context.Context, msg string, fields Fields) {
+	Default().Log(ctx, LevelInfo, msg, fields)
+}
+
+func Warn(ctx context.Context, msg string, fields Fields) {
+	Default().Log(ctx, LevelWarn, msg, fields)
+}
+
+func Error(ctx context.Context, msg string, fields Fields) {
+	Default().Log(ctx, LevelError, msg, fields)
+}
+
+func Fatal(ctx context.Context, msg string, fields Fields) {
+	Default().Fatal(ctx, msg, fields)
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Middleware log every served request, replacing chi middleware.Logger
+func Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			Info(r.Context(), "request served", Fields{
+				"http_method": r.Method,
+				"http_path":   r.URL.Path,
+				"http_proto":  r.Proto,
+				"http_status": status,
+				"bytes":       ww.BytesWritten(),
+				"remote_addr": r.RemoteAddr,
+				"duration_ms": float64(time.Since(start)) / float64(time.Millisecond),
+			})
+		}()
+
+		next.ServeHTTP(ww, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	cacheLookups = meter.NewInt64Counter("cache.lookups",
+		metric.WithDescription("Number of cache lookups by cache and result, hit, miss or stale"))
+	cacheEvictions = meter.NewInt64Counter("cache.evictions",
+		metric.WithDescription("Number of entries evicted from a full cache"))
+)
+
+// RecordCacheLookup count a lookup in the named cache, result is hit, miss or stale
+func RecordCacheLookup(ctx context.Context, cache string, result string) {
+	cacheLookups.Add(ctx, 1,
+		attribute.String("cache", cache),
+		attribute.String("result", result),
+	)
+}
+
+// RecordCacheEvictions count entries evicted from the named cache
+func RecordCacheEvictions(ctx context.Context, cache string, count int) {
+	if count == 0 {
+		return
+	}
+	cacheEvictions.Add(ctx, int64(count), attribute.String("cache", cache))
+}
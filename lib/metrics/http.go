@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+
+	"weather/lib/route"
+)
+
+const instrumentationName = "weather/lib/metrics"
+
+// instruments are created on the global meter, they forward to the provider installed by InitMeter
+var (
+	meter = metric.Must(global.Meter(instrumentationName))
+
+	serverRequests = meter.NewInt64Counter("http.server.requests",
+		metric.WithDescription("Number of HTTP requests served"))
+	serverDuration = meter.NewFloat64ValueRecorder("http.server.duration",
+		metric.WithDescription("Duration of HTTP requests served"),
+		metric.WithUnit(unit.Milliseconds))
+	serverActive = meter.NewInt64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of HTTP requests in flight"))
+
+	clientRequests = meter.NewInt64Counter("http.client.requests",
+		metric.WithDescription("Number of outgoing HTTP requests"))
+	clientDuration = meter.NewFloat64ValueRecorder("http.client.duration",
+		metric.WithDescription("Duration of outgoing HTTP requests"),
+		metric.WithUnit(unit.Milliseconds))
+	clientActive = meter.NewInt64UpDownCounter("http.client.active_requests",
+		metric.WithDescription("Number of outgoing HTTP requests in flight"))
+	clientBreakerTransitions = meter.NewInt64Counter("http.client.circuit_breaker.transitions",
+		metric.WithDescription("Number of circuit breaker state changes by host and new state"))
+)
+
+// Middleware record request count, latency and in flight requests by chi route and status
+func Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		start := time.Now()
+
+		routeLabels := []attribute.KeyValue{
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPRouteKey.String(route.Pattern(r)),
+		}
+
+		serverActive.Add(ctx, 1, routeLabels...)
+		defer serverActive.Add(ctx, -1, routeLabels...)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := append(routeLabels, semconv.HTTPStatusCodeKey.Int(status))
+
+		serverRequests.Add(ctx, 1, labels...)
+		serverDuration.Record(ctx, milliseconds(time.Since(start)), labels...)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// StartClientRequest start measuring an outgoing request,
+// the returned func must be called once with the response status, 0 when the request failed
+func StartClientRequest(ctx context.Context, req *http.Request) func(statusCode int) {
+	start := time.Now()
+	hostLabels := []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(req.Method),
+		semconv.NetPeerNameKey.String(req.URL.Hostname()),
+	}
+
+	clientActive.Add(ctx, 1, hostLabels...)
+
+	return func(statusCode int) {
+		clientActive.Add(ctx, -1, hostLabels...)
+
+		labels := append(hostLabels, semconv.HTTPStatusCodeKey.Int(statusCode))
+		clientRequests.Add(ctx, 1, labels...)
+		clientDuration.Record(ctx, milliseconds(time.Since(start)), labels...)
+	}
+}
+
+// RecordBreakerTransition count a circuit breaker of host entering state
+func RecordBreakerTransition(ctx context.Context, host string, state string) {
+	clientBreakerTransitions.Add(ctx, 1,
+		semconv.HTTPHostKey.String(host),
+		attribute.String("state", state),
+	)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
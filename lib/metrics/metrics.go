@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric/global"
+	export "go.opentelemetry.io/otel/sdk/export/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	selector "go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"weather/lib/logging"
+	"weather/lib/multierr"
+)
+
+const (
+	collectPeriod   = 10 * time.Second
+	shutdownTimeout = 5 * time.Second
+)
+
+// latencyBoundaries histogram buckets in milliseconds
+var latencyBoundaries = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Provider own the metric controller and exporter installed by InitMeter
+type Provider struct {
+	controller *controller.Controller
+	exporter   *otlpmetric.Exporter
+	handler    http.Handler
+}
+
+// Handler return the prometheus scrape handler, nil for push exporters
+func (p *Provider) Handler() http.Handler {
+	return p.handler
+}
+
+// Shutdown collect and push metrics one last time then stop the exporter,
+// giving up after shutdownTimeout when ctx has no earlier deadline
+func (p *Provider) Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+
+	var errs multierr.Errors
+	if err := p.controller.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop controller: %w", err))
+	}
+	if p.exporter != nil {
+		if err := p.exporter.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop exporter: %w", err))
+		}
+	}
+
+	return errs.Err()
+}
+
+func InitMeter(ctx context.Context, kind string, serviceName string, endpoint string) (*Provider, error) {
+	logging.Info(ctx, "init meter", logging.Fields{"kind": kind, "endpoint": endpoint})
+
+	meterLabels := []attribute.KeyValue{
+		attribute.String("service.name", serviceName),
+		attribute.String("Host", os.Getenv("HOSTNAME")),
+	}
+	res := resource.NewSchemaless(meterLabels...)
+
+	aggregatorSelector := selector.NewWithHistogramDistribution(histogram.WithExplicitBoundaries(latencyBoundaries))
+
+	if strings.EqualFold(kind, "prometheus") {
+		ctrl := controller.New(
+			processor.New(aggregatorSelector, export.CumulativeExportKindSelector(), processor.WithMemory(true)),
+			controller.WithResource(res),
+		)
+
+		exporterPrometheus, err := prometheus.New(prometheus.Config{DefaultHistogramBoundaries: latencyBoundaries}, ctrl)
+		if err != nil {
+			return nil, err
+		}
+		global.SetMeterProvider(exporterPrometheus.MeterProvider())
+
+		return &Provider{
+			controller: ctrl,
+			handler:    exporterPrometheus,
+		}, nil
+	} else if strings.EqualFold(kind, "otlpmetric") {
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint)}
+		// endpoint may be given as an URL, https switch to TLS
+		if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+			opts = []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(u.Host)}
+			if u.Scheme != "https" {
+				opts = append(opts, otlpmetricgrpc.WithInsecure())
+			}
+		} else {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+
+		// process attributes are only pushed, as prometheus labels they would change on every restart
+		processDetector, err := resource.New(ctx, resource.WithOSType(), resource.WithProcess(), resource.WithProcessExecutableName())
+		if err != nil {
+			return nil, err
+		}
+		res, err = resource.Merge(processDetector, res)
+		if err != nil {
+			return nil, err
+		}
+
+		exporterOtel, err := otlpmetricgrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		ctrl := controller.New(
+			processor.New(aggregatorSelector, exporterOtel),
+			controller.WithExporter(exporterOtel),
+			controller.WithResource(res),
+			controller.WithCollectPeriod(collectPeriod),
+		)
+		if err := ctrl.Start(ctx); err != nil {
+			return nil, err
+		}
+		global.SetMeterProvider(ctrl.MeterProvider())
+
+		return &Provider{
+			controller: ctrl,
+			exporter:   exporterOtel,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unrecognized meter kind %q", kind)
+}
@@ -0,0 +1,22 @@
+package multierr
+
+import "strings"
+
+// Errors aggregate the failures of steps which all run even when one of them fail, such as shutdowns
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Err return nil when there is no error, e otherwise
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,309 @@
+package openmeteo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	libhttp "weather/lib/http"
+	"weather/lib/tracing"
+)
+
+const (
+	// ForecastURL is the default base URL of the forecast API
+	ForecastURL = "https://api.open-meteo.com"
+	// GeocodingURL is the default base URL of the geocoding API
+	GeocodingURL = "https://geocoding-api.open-meteo.com"
+
+	currentVariables = "temperature_2m,relative_humidity_2m,weather_code"
+	hourlyVariables  = "temperature_2m,relative_humidity_2m,precipitation_probability,rain,snowfall,weather_code"
+	forecastDays     = 5
+)
+
+// TemperatureUnit of the responses
+type TemperatureUnit string
+
+const (
+	Celsius    TemperatureUnit = "celsius"
+	Fahrenheit TemperatureUnit = "fahrenheit"
+)
+
+var (
+	// ErrLocationNotFound is returned when geocoding find no place matching the name
+	ErrLocationNotFound = errors.New("location not found")
+	// ErrRateLimited is returned when open-meteo refuse more requests for now
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUpstream is returned for any other failed response from open-meteo
+	ErrUpstream = errors.New("upstream error")
+)
+
+// Client query the open-meteo forecast and geocoding APIs, no API key is needed
+type Client struct {
+	httpClient      *libhttp.Client
+	forecastURL     string
+	geocodingURL    string
+	temperatureUnit TemperatureUnit
+}
+
+// Option configure a Client
+type Option func(*Client)
+
+// WithForecastURL replace ForecastURL, e.g. with a local stub server
+func WithForecastURL(baseURL string) Option {
+	return func(c *Client) {
+		c.forecastURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithGeocodingURL replace GeocodingURL, e.g. with a local stub server
+func WithGeocodingURL(baseURL string) Option {
+	return func(c *Client) {
+		c.geocodingURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTemperatureUnit replace Celsius
+func WithTemperatureUnit(unit TemperatureUnit) Option {
+	return func(c *Client) {
+		c.temperatureUnit = unit
+	}
+}
+
+// New build a client sending its requests with client
+func New(client *libhttp.Client, opts ...Option) (*Client, error) {
+	c := &Client{
+		httpClient:      client,
+		forecastURL:     ForecastURL,
+		geocodingURL:    GeocodingURL,
+		temperatureUnit: Celsius,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if client == nil {
+		return nil, errors.New("no http client configured")
+	}
+	if c.temperatureUnit != Celsius && c.temperatureUnit != Fahrenheit {
+		return nil, fmt.Errorf("invalid temperature unit %q: must be celsius or fahrenheit", c.temperatureUnit)
+	}
+	for _, u := range []string{c.forecastURL, c.geocodingURL} {
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return nil, fmt.Errorf("invalid base url %q: must start with http:// or https://", u)
+		}
+	}
+
+	return c, nil
+}
+
+// NewFromEnv build a client configured by OPENMETEO_FORECAST_URL, OPENMETEO_GEOCODING_URL and OPENMETEO_TEMPERATURE_UNIT
+func NewFromEnv(client *libhttp.Client) (*Client, error) {
+	var opts []Option
+	if v := strings.TrimSpace(os.Getenv("OPENMETEO_FORECAST_URL")); v != "" {
+		opts = append(opts, WithForecastURL(v))
+	}
+	if v := strings.TrimSpace(os.Getenv("OPENMETEO_GEOCODING_URL")); v != "" {
+		opts = append(opts, WithGeocodingURL(v))
+	}
+	if v := strings.TrimSpace(os.Getenv("OPENMETEO_TEMPERATURE_UNIT")); v != "" {
+		opts = append(opts, WithTemperatureUnit(TemperatureUnit(strings.ToLower(v))))
+	}
+
+	return New(client, opts...)
+}
+
+// TemperatureUnit of the responses
+func (c *Client) TemperatureUnit() TemperatureUnit {
+	return c.temperatureUnit
+}
+
+// Location is a place found by geocoding
+type Location struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	CountryCode string  `json:"country_code"`
+	Country     string  `json:"country"`
+	Timezone    string  `json:"timezone"`
+}
+
+type geocodingResponse struct {
+	Results []Location `json:"results"`
+}
+
+// Current is the weather now, times are ISO 8601 in UTC without seconds
+type Current struct {
+	Time             string  `json:"time"`
+	Temperature      float64 `json:"temperature_2m"`
+	RelativeHumidity int     `json:"relative_humidity_2m"`
+	WeatherCode      int     `json:"weather_code"`
+}
+
+// Hourly hold one value per hour in each slice, all of the length of Time.
+// Rain is in mm, Snowfall in cm and PrecipitationProbability in percent
+type Hourly struct {
+	Time                     []string  `json:"time"`
+	Temperature              []float64 `json:"temperature_2m"`
+	RelativeHumidity         []int     `json:"relative_humidity_2m"`
+	PrecipitationProbability []int     `json:"precipitation_probability"`
+	Rain                     []float64 `json:"rain"`
+	Snowfall                 []float64 `json:"snowfall"`
+	WeatherCode              []int     `json:"weather_code"`
+}
+
+// ForecastResponse of the forecast API, Hourly is empty when only the current weather was asked
+type ForecastResponse struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Current   Current `json:"current"`
+	Hourly    Hourly  `json:"hourly"`
+}
+
+type errorResponse struct {
+	Error  bool   `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// APIError is a failed response from open-meteo, match one of the Err* values with errors.Is
+type APIError struct {
+	StatusCode int
+	Reason     string
+
+	kind error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("open-meteo: %v (HTTP %d): %s", e.kind, e.StatusCode, e.Reason)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// Geocode return the best match for a place name
+func (c *Client) Geocode(ctx context.Context, name string, tracer trace.Tracer) (*Location, error) {
+	spanCtx, span := tracer.Start(ctx, "call_openmeteo_Geocode", trace.WithAttributes(attribute.Key("openmeteo_Geocode").String("returning_location_based_on_name")))
+	defer span.End()
+
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("count", "1")
+	query.Set("format", "json")
+
+	body, err := c.get(spanCtx, c.geocodingURL+"/v1/search?"+query.Encode(), tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	var gr geocodingResponse
+	if err := json.Unmarshal(body, &gr); err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	if len(gr.Results) == 0 {
+		return nil, tracing.RecordError(span, fmt.Errorf("%q: %w", name, ErrLocationNotFound))
+	}
+
+	span.SetStatus(codes.Ok, "requestGeocodeSuccessful")
+	return &gr.Results[0], nil
+}
+
+// Current return the weather now at a coordinate
+func (c *Client) Current(ctx context.Context, lat, long float64, tracer trace.Tracer) (*ForecastResponse, error) {
+	spanCtx, span := tracer.Start(ctx, "call_openmeteo_Current", trace.WithAttributes(attribute.Key("openmeteo_Current").String("returning_weather_based_on_coordinates")))
+	defer span.End()
+
+	query := c.coordinatesQuery(lat, long)
+	query.Set("current", currentVariables)
+
+	fr, err := c.forecast(spanCtx, query, tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetStatus(codes.Ok, "requestCurrentSuccessful")
+	return fr, nil
+}
+
+// Forecast return the hourly forecast of the next five days at a coordinate
+func (c *Client) Forecast(ctx context.Context, lat, long float64, tracer trace.Tracer) (*ForecastResponse, error) {
+	spanCtx, span := tracer.Start(ctx, "call_openmeteo_Forecast", trace.WithAttributes(attribute.Key("openmeteo_Forecast").String("returning_forecast_based_on_coordinates")))
+	defer span.End()
+
+	query := c.coordinatesQuery(lat, long)
+	query.Set("hourly", hourlyVariables)
+	query.Set("forecast_days", fmt.Sprint(forecastDays))
+
+	fr, err := c.forecast(spanCtx, query, tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetStatus(codes.Ok, "requestForecastSuccessful")
+	return fr, nil
+}
+
+func (c *Client) coordinatesQuery(lat, long float64) url.Values {
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", lat))
+	query.Set("longitude", fmt.Sprintf("%f", long))
+	query.Set("temperature_unit", string(c.temperatureUnit))
+	query.Set("timezone", "GMT")
+	return query
+}
+
+func (c *Client) forecast(ctx context.Context, query url.Values, tracer trace.Tracer) (*ForecastResponse, error) {
+	body, err := c.get(ctx, c.forecastURL+"/v1/forecast?"+query.Encode(), tracer)
+	if err != nil {
+		return nil, err
+	}
+
+	var fr ForecastResponse
+	if err := json.Unmarshal(body, &fr); err != nil {
+		return nil, err
+	}
+	return &fr, nil
+}
+
+// get return the body of a successful response, or the typed error of a failed one
+func (c *Client) get(ctx context.Context, requestURL string, tracer trace.Tracer) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.httpClient.Do(ctx, req, tracer)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: res.StatusCode, Reason: strings.TrimSpace(string(body)), kind: ErrUpstream}
+		var payload errorResponse
+		if json.Unmarshal(body, &payload) == nil && payload.Reason != "" {
+			apiErr.Reason = payload.Reason
+		}
+		if res.StatusCode == http.StatusTooManyRequests {
+			apiErr.kind = ErrRateLimited
+		}
+		return nil, apiErr
+	}
+
+	return body, nil
+}
@@ -0,0 +1,23 @@
+package openmeteo
+
+// Condition return the weather group of a WMO weather code,
+// named like the openweathermap main conditions so both backends read the same
+func Condition(code int) string {
+	switch {
+	case code == 0:
+		return "Clear"
+	case code <= 3:
+		return "Clouds"
+	case code == 45 || code == 48:
+		return "Fog"
+	case code >= 51 && code <= 57:
+		return "Drizzle"
+	case code >= 61 && code <= 67, code >= 80 && code <= 82:
+		return "Rain"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "Snow"
+	case code >= 95 && code <= 99:
+		return "Thunderstorm"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,183 @@
+package owm
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	libhttp "weather/lib/http"
+)
+
+/*
+Unit system of temperatures and wind speeds in responses
+*/
+type Units string
+
+const (
+	// Standard is Kelvin and meter/sec
+	Standard Units = "standard"
+	// Metric is Celsius and meter/sec
+	Metric Units = "metric"
+	// Imperial is Fahrenheit and miles/hour
+	Imperial Units = "imperial"
+)
+
+const (
+	defaultScheme = "http"
+	apiVersion    = "data/2.5"
+)
+
+/*
+Configure an OpenWeatherMap client
+*/
+type Option func(*OpenWeatherMap)
+
+/*
+Units of the responses, Metric by default
+*/
+func WithUnits(units Units) Option {
+	return func(owm *OpenWeatherMap) {
+		owm.units = units
+	}
+}
+
+/*
+Language of the weather descriptions, e.g. fr or zh_cn. English by default
+*/
+func WithLang(lang string) Option {
+	return func(owm *OpenWeatherMap) {
+		owm.lang = lang
+	}
+}
+
+/*
+Scheme used to reach openweathermap, http or https. http by default
+*/
+func WithScheme(scheme string) Option {
+	return func(owm *OpenWeatherMap) {
+		owm.scheme = scheme
+	}
+}
+
+/*
+Host, with an optional port and path prefix, serving the API. APIURL by default
+*/
+func WithBaseURL(baseURL string) Option {
+	return func(owm *OpenWeatherMap) {
+		owm.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+/*
+Limiter keeping the calls within the plan quota, none by default
+*/
+func WithLimiter(limiter *Limiter) Option {
+	return func(owm *OpenWeatherMap) {
+		owm.limiter = limiter
+	}
+}
+
+/*
+Build a client sending its requests with client
+*/
+func New(apiKey string, client *libhttp.Client, opts ...Option) (*OpenWeatherMap, error) {
+	owm := &OpenWeatherMap{
+		APIKEY:  apiKey,
+		Client:  client,
+		units:   Metric,
+		scheme:  defaultScheme,
+		baseURL: APIURL,
+	}
+	for _, opt := range opts {
+		opt(owm)
+	}
+
+	switch owm.units {
+	case Standard, Metric, Imperial:
+	default:
+		return nil, fmt.Errorf("invalid units %q: must be standard, metric or imperial", owm.units)
+	}
+	if owm.scheme != "http" && owm.scheme != "https" {
+		return nil, fmt.Errorf("invalid scheme %q: must be http or https", owm.scheme)
+	}
+	if owm.baseURL == "" {
+		return nil, fmt.Errorf("empty base url")
+	}
+
+	return owm, nil
+}
+
+/*
+Build a client configured by OWM_APP_ID, OWM_UNITS, OWM_LANG, OWM_SCHEME, OWM_BASE_URL and the OWM_RATE_* variables
+*/
+func NewFromEnv(client *libhttp.Client) (*OpenWeatherMap, error) {
+	var opts []Option
+	limiter, err := LimiterFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		opts = append(opts, WithLimiter(limiter))
+	}
+	if v := strings.TrimSpace(os.Getenv("OWM_UNITS")); v != "" {
+		opts = append(opts, WithUnits(Units(strings.ToLower(v))))
+	}
+	if v := strings.TrimSpace(os.Getenv("OWM_LANG")); v != "" {
+		opts = append(opts, WithLang(v))
+	}
+	if v := strings.TrimSpace(os.Getenv("OWM_SCHEME")); v != "" {
+		opts = append(opts, WithScheme(strings.ToLower(v)))
+	}
+	if v := strings.TrimSpace(os.Getenv("OWM_BASE_URL")); v != "" {
+		opts = append(opts, WithBaseURL(v))
+	}
+
+	return New(os.Getenv("OWM_APP_ID"), client, opts...)
+}
+
+/*
+Units of the responses
+*/
+func (owm *OpenWeatherMap) Units() Units {
+	if owm.units == "" {
+		return Metric
+	}
+	return owm.units
+}
+
+/*
+Language of the weather descriptions, empty for English
+*/
+func (owm *OpenWeatherMap) Lang() string {
+	return owm.lang
+}
+
+/*
+Build the URL of an API endpoint for an already escaped location query such as q=depok
+*/
+func (owm *OpenWeatherMap) buildURL(endpoint string, location string) string {
+	scheme, baseURL := owm.scheme, owm.baseURL
+	// zero values keep the historical behaviour of clients not built by New
+	if scheme == "" {
+		scheme = defaultScheme
+	}
+	if baseURL == "" {
+		baseURL = APIURL
+	}
+
+	query := location + "&units=" + url.QueryEscape(string(owm.Units()))
+	if owm.lang != "" {
+		query += "&lang=" + url.QueryEscape(owm.lang)
+	}
+	query += "&APPID=" + url.QueryEscape(owm.APIKEY)
+
+	return fmt.Sprintf("%s://%s/%s/%s?%s", scheme, baseURL, apiVersion, endpoint, query)
+}
+
+/*
+Location query of a city name
+*/
+func cityQuery(city string) string {
+	return "q=" + url.QueryEscape(city)
+}
@@ -0,0 +1,86 @@
+package owm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCityNotFound is returned when openweathermap know no location matching the query
+	ErrCityNotFound = errors.New("city not found")
+	// ErrUnauthorized is returned when the API key is missing, invalid or not allowed on the endpoint
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited is returned when the API key exceeded its quota, see APIError.RetryAfter
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUpstream is returned for any other failed response from openweathermap
+	ErrUpstream = errors.New("upstream error")
+)
+
+/*
+Error payload of openweathermap, cod is a number or a string depending on the endpoint
+*/
+type errorResponse struct {
+	Cod     json.RawMessage `json:"cod"`
+	Message string          `json:"message"`
+}
+
+/*
+Failed response from openweathermap, or call refused by the client side Limiter,
+match one of the Err* values with errors.Is
+*/
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is how long to wait before calling again, only set for ErrRateLimited
+	// and zero when openweathermap gave no hint
+	RetryAfter time.Duration
+
+	kind error
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("openweathermap: %v (HTTP %d)", e.kind, e.StatusCode)
+	}
+	return fmt.Sprintf("openweathermap: %v (HTTP %d): %s", e.kind, e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+/*
+Build the typed error of a failed response status, retryAfter is ignored unless rate limited
+*/
+func NewAPIError(statusCode int, message string, retryAfter time.Duration) *APIError {
+	e := &APIError{StatusCode: statusCode, Message: message}
+
+	switch statusCode {
+	case http.StatusNotFound:
+		e.kind = ErrCityNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		e.kind = ErrUnauthorized
+	case http.StatusTooManyRequests:
+		e.kind = ErrRateLimited
+		e.RetryAfter = retryAfter
+	default:
+		e.kind = ErrUpstream
+	}
+	return e
+}
+
+/*
+Parse the {cod, message} payload of a failed response
+*/
+func parseAPIError(statusCode int, body []byte, retryAfter time.Duration) *APIError {
+	var payload errorResponse
+	message := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Message != "" {
+		message = payload.Message
+	}
+	return NewAPIError(statusCode, message, retryAfter)
+}
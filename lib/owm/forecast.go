@@ -0,0 +1,100 @@
+package owm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/tracing"
+)
+
+/*
+Get five days forecast, in 3 hours steps, from a city - openweathermap
+*/
+func (owm *OpenWeatherMap) ForecastFromCity(ctx context.Context, city string, tracer trace.Tracer) (*ForecastResponse, error) {
+	spanCtx, span := tracer.Start(ctx, "call_owm_ForecastFromCity", trace.WithAttributes(attribute.Key("owm_ForecastFromCity").String("returning_forecast_based_on_city")))
+	defer span.End()
+
+	fr, err := owm.forecast(spanCtx, cityQuery(city), tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetStatus(codes.Ok, "requestForecastFromCitySuccessful")
+	return fr, nil
+}
+
+/*
+Get five days forecast, in 3 hours steps, from a coordinate - openweathermap
+*/
+func (owm *OpenWeatherMap) ForecastFromCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*ForecastResponse, error) {
+	spanCtx, span := tracer.Start(ctx, "call_owm_ForecastFromCoordinates", trace.WithAttributes(attribute.Key("owm_ForecastFromCoordinates").String("returning_forecast_based_on_coordinates")))
+	defer span.End()
+
+	fr, err := owm.forecast(spanCtx, fmt.Sprintf("lat=%f&lon=%f", lat, long), tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetStatus(codes.Ok, "requestForecastFromCoordinatesSuccessful")
+	return fr, nil
+}
+
+/*
+Get five days forecast, in 3 hours steps, from a zip code - openweathermap
+*/
+func (owm *OpenWeatherMap) ForecastFromZip(ctx context.Context, zip int, tracer trace.Tracer) (*ForecastResponse, error) {
+	spanCtx, span := tracer.Start(ctx, "call_owm_ForecastFromZip", trace.WithAttributes(attribute.Key("owm_ForecastFromZip").String("returning_forecast_based_on_zipcode")))
+	defer span.End()
+
+	fr, err := owm.forecast(spanCtx, fmt.Sprintf("zip=%d", zip), tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetStatus(codes.Ok, "requestForecastFromZipSuccessful")
+	return fr, nil
+}
+
+/*
+Get five days forecast, in 3 hours steps, from a city id - openweathermap
+*/
+func (owm *OpenWeatherMap) ForecastFromCityID(ctx context.Context, id int, tracer trace.Tracer) (*ForecastResponse, error) {
+	spanCtx, span := tracer.Start(ctx, "call_owm_ForecastFromCityID", trace.WithAttributes(attribute.Key("owm_ForecastFromCityID").String("returning_forecast_based_on_city_id")))
+	defer span.End()
+
+	fr, err := owm.forecast(spanCtx, fmt.Sprintf("id=%d", id), tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetStatus(codes.Ok, "requestForecastFromCityIDSuccessful")
+	return fr, nil
+}
+
+/*
+Request the forecast endpoint with an already escaped location query
+*/
+func (owm *OpenWeatherMap) forecast(ctx context.Context, location string, tracer trace.Tracer) (*ForecastResponse, error) {
+	if owm.APIKEY == "" {
+		// No API keys present, return error
+		return nil, errors.New("no api keys present")
+	}
+	body, err := owm.makeAPIRequest(ctx, owm.buildURL("forecast", location), tracer)
+	if err != nil {
+		return nil, err
+	}
+	var fr ForecastResponse
+
+	// unmarshal the byte stream into a Go data type
+	if err := json.Unmarshal(body, &fr); err != nil {
+		return nil, err
+	}
+
+	return &fr, nil
+}
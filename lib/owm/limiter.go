@@ -0,0 +1,116 @@
+package owm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/ratelimit"
+)
+
+/*
+Token bucket keeping the calls of a client within the openweathermap plan quota.
+Each call take a token, tokens come back at the configured rate up to burst
+*/
+type Limiter struct {
+	bucket *ratelimit.Bucket
+	// wait for a token, bounded by the context deadline, instead of failing right away
+	wait bool
+}
+
+/*
+Build a limiter allowing ratePerMinute calls with bursts of up to burst calls.
+When wait is false calls beyond the quota fail right away with ErrRateLimited
+*/
+func NewLimiter(ratePerMinute float64, burst int, wait bool) *Limiter {
+	return &Limiter{
+		bucket: ratelimit.NewBucket(ratePerMinute/60, burst),
+		wait:   wait,
+	}
+}
+
+/*
+Build the limiter configured by OWM_RATE_LIMIT in calls per minute, OWM_RATE_BURST and OWM_RATE_MODE,
+wait or reject. nil when OWM_RATE_LIMIT is unset or 0
+*/
+func LimiterFromEnv() (*Limiter, error) {
+	v := strings.TrimSpace(os.Getenv("OWM_RATE_LIMIT"))
+	if v == "" {
+		return nil, nil
+	}
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate < 0 {
+		return nil, fmt.Errorf("invalid OWM_RATE_LIMIT %q: must be a non negative number of calls per minute", v)
+	}
+	if rate == 0 {
+		return nil, nil
+	}
+
+	burst := 1
+	if v := strings.TrimSpace(os.Getenv("OWM_RATE_BURST")); v != "" {
+		if burst, err = strconv.Atoi(v); err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid OWM_RATE_BURST %q: must be a positive integer", v)
+		}
+	}
+
+	wait := true
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("OWM_RATE_MODE"))); mode {
+	case "", "wait":
+	case "reject":
+		wait = false
+	default:
+		return nil, fmt.Errorf("invalid OWM_RATE_MODE %q: must be wait or reject", mode)
+	}
+
+	return NewLimiter(rate, burst, wait), nil
+}
+
+/*
+Take a token for one call, waiting for it if configured so. The wait is recorded as an event of the span of ctx
+*/
+func (l *Limiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	span := trace.SpanFromContext(ctx)
+
+	delay := l.bucket.Reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	retryAfter := time.Duration(math.Ceil(delay.Seconds())) * time.Second
+	if !l.wait {
+		l.bucket.Cancel()
+		span.AddEvent("owm.rate_limiter.rejected", trace.WithAttributes(attribute.Float64("wait_ms", milliseconds(delay))))
+		return NewAPIError(http.StatusTooManyRequests, "client side rate limit reached", retryAfter)
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		l.bucket.Cancel()
+		span.AddEvent("owm.rate_limiter.rejected", trace.WithAttributes(attribute.Float64("wait_ms", milliseconds(delay))))
+		return NewAPIError(http.StatusTooManyRequests, "client side rate limit wait exceed the deadline", retryAfter)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		span.AddEvent("owm.rate_limiter.waited", trace.WithAttributes(attribute.Float64("wait_ms", milliseconds(delay))))
+		return nil
+	case <-ctx.Done():
+		l.bucket.Cancel()
+		return ctx.Err()
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,115 @@
+package owm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	libhttp "weather/lib/http"
+)
+
+func TestLimiterAcquire(t *testing.T) {
+	// one token every 50ms
+	const interval = 50 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		wait    bool
+		timeout time.Duration
+		// minimum duration of the second acquire
+		minWait time.Duration
+		wantErr error
+	}{
+		{"wait for the token", true, time.Second, interval / 2, nil},
+		{"reject without waiting", false, time.Second, 0, ErrRateLimited},
+		{"deadline shorter than the wait", true, interval / 5, 0, ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(float64(time.Minute/interval), 1, tt.wait)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			if err := l.acquire(ctx); err != nil {
+				t.Fatalf("first acquire: %v", err)
+			}
+
+			start := time.Now()
+			err := l.acquire(ctx)
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second acquire error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed < tt.minWait {
+				t.Errorf("second acquire took %s, want at least %s", elapsed, tt.minWait)
+			}
+			if err != nil {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+					t.Errorf("error %v carry no Retry-After", err)
+				}
+				// a refused call give its token back
+				if _, reset := l.bucket.Remaining(); reset > interval {
+					t.Errorf("bucket full again in %s, the refused call kept its token", reset)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterCancelReturnsToken(t *testing.T) {
+	l := NewLimiter(1, 1, true)
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := l.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want %v", err, context.Canceled)
+	}
+
+	// one token a minute, the bucket is full a minute after the first call unless the canceled one kept its token
+	if _, reset := l.bucket.Remaining(); reset > time.Minute {
+		t.Errorf("bucket full again in %s, the canceled call kept its token", reset)
+	}
+}
+
+func TestLimiterCountsRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	owm, err := New("key", libhttp.NewClient(),
+		WithBaseURL(strings.TrimPrefix(srv.URL, "http://")),
+		WithLimiter(NewLimiter(1, 1, false)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = owm.CurrentWeatherFromCity(context.Background(), "depok", trace.NewNoopTracerProvider().Tracer("test"))
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("error = %v, want %v", err, ErrRateLimited)
+	}
+	// the client retries a 429, each retry need a token of its own
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("sent %d requests to openweathermap, want 1", got)
+	}
+}
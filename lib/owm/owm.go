@@ -1,6 +1,6 @@
 /*
 Origin : https://github.com/ramsgoli/Golang-OpenWeatherMap
-Hardcoded unit from imperial to metric
+Metric units by default, see config.go for units, language and endpoint options
 Added OpenTelemetry Instrumentation by tonny@segmentationfault.xyz
 */
 
@@ -19,6 +19,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	libhttp "weather/lib/http"
+	"weather/lib/tracing"
 )
 
 /*
@@ -26,14 +27,28 @@ Define API response fields
 */
 type OpenWeatherMap struct {
 	APIKEY string
+	// Client send the requests to openweathermap
+	Client *libhttp.Client
+
+	units   Units
+	lang    string
+	scheme  string
+	baseURL string
+	limiter *Limiter
 }
 
 /*
 Return response fields of City data
 */
 type City struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Coord      `json:"coord"`
+	Country    string `json:"country"`
+	Population int    `json:"population"`
+	Timezone   int    `json:"timezone"`
+	Sunrise    int    `json:"sunrise"`
+	Sunset     int    `json:"sunset"`
 }
 
 /*
@@ -55,11 +70,12 @@ type Weather struct {
 }
 
 /*
-Return wind condition
+Return wind condition, gust is only reported by some stations
 */
 type Wind struct {
 	Speed float64 `json:"speed"`
 	Deg   float64 `json:"deg"`
+	Gust  float64 `json:"gust,omitempty"`
 }
 
 /*
@@ -70,52 +86,98 @@ type Clouds struct {
 }
 
 /*
-Return rain condition
+Return rain volume in mm over the last hour and the last 3 hours, each only when reported.
+Forecast steps only carry the 3 hours volume.
+ThreeHr was named Threehr and was an int before the volumes became fractional
 */
 type Rain struct {
-	Threehr int `json:"3h"`
+	OneHr   float64 `json:"1h,omitempty"`
+	ThreeHr float64 `json:"3h,omitempty"`
 }
 
 /*
-Return temperature data
+Return snow volume in mm over the last hour and the last 3 hours, each only when reported.
+Forecast steps only carry the 3 hours volume
+*/
+type Snow struct {
+	OneHr   float64 `json:"1h,omitempty"`
+	ThreeHr float64 `json:"3h,omitempty"`
+}
+
+/*
+Return temperature data, pressures are in hPa. Sea and ground level pressures are only reported for some locations
 */
 type Main struct {
-	Temp     float64 `json:"temp"`
-	Pressure int     `json:"pressure"`
-	Humidity int     `json:"humidity"`
-	TempMin  float64 `json:"temp_min"`
-	TempMax  float64 `json:"temp_max"`
+	Temp      float64 `json:"temp"`
+	FeelsLike float64 `json:"feels_like"`
+	Pressure  int     `json:"pressure"`
+	Humidity  int     `json:"humidity"`
+	TempMin   float64 `json:"temp_min"`
+	TempMax   float64 `json:"temp_max"`
+	SeaLevel  int     `json:"sea_level,omitempty"`
+	GrndLevel int     `json:"grnd_level,omitempty"`
+}
+
+/*
+Return country and sun times, as unix UTC timestamps, of the location.
+Type and ID of the station are internal to openweathermap and not always sent
+*/
+type Sys struct {
+	Type    int    `json:"type,omitempty"`
+	ID      int    `json:"id,omitempty"`
+	Country string `json:"country"`
+	Sunrise int    `json:"sunrise"`
+	Sunset  int    `json:"sunset"`
 }
 
 /*
 Define API response objects (compose of the above fields)
 */
 type CurrentWeatherResponse struct {
-	Coord   `json:"coord"`
-	Weather []Weather `json:"weather"`
-	Main    `json:"main"`
-	Wind    `json:"wind"`
-	Rain    `json:"rain"`
-	Clouds  `json:"clouds"`
-	DT      int    `json:"dt"`
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
+	Coord      `json:"coord"`
+	Weather    []Weather `json:"weather"`
+	Base       string    `json:"base"`
+	Main       `json:"main"`
+	Visibility int `json:"visibility"`
+	Wind       `json:"wind"`
+	Clouds     `json:"clouds"`
+	// Rain and Snow are nil when there was no precipitation
+	Rain *Rain `json:"rain,omitempty"`
+	Snow *Snow `json:"snow,omitempty"`
+	DT   int   `json:"dt"`
+	Sys  `json:"sys"`
+	// Timezone is the shift in seconds from UTC
+	Timezone int    `json:"timezone"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Cod      int    `json:"cod"`
+}
+
+/*
+Return one 3 hours step of a forecast
+*/
+type ForecastItem struct {
+	DT         int `json:"dt"`
+	Main       `json:"main"`
+	Weather    []Weather `json:"weather"`
+	Clouds     `json:"clouds"`
+	Wind       `json:"wind"`
+	Visibility int `json:"visibility"`
+	// Pop is the probability of precipitation, between 0 and 1
+	Pop float64 `json:"pop"`
+	// Rain and Snow are nil when no precipitation is expected
+	Rain  *Rain  `json:"rain,omitempty"`
+	Snow  *Snow  `json:"snow,omitempty"`
+	DTTxt string `json:"dt_txt"`
 }
 
 /*
-Response from openweathermap
+Five days forecast response from openweathermap
 */
 type ForecastResponse struct {
-	City    `json:"city"`
-	Coord   `json:"coord"`
-	Country string `json:"country"`
-	List    []struct {
-		DT      int `json:"dt"`
-		Main    `json:"main"`
-		Weather `json:"weather"`
-		Clouds  `json:"clouds"`
-		Wind    `json:"wind"`
-	} `json:"list"`
+	Cnt  int            `json:"cnt"`
+	List []ForecastItem `json:"list"`
+	City `json:"city"`
 }
 
 /*
@@ -128,18 +190,28 @@ const (
 /*
 Build request to openweathermap
 */
-func makeAPIRequest(ctx context.Context, url string, tracer trace.Tracer) ([]byte, error) {
+func (owm *OpenWeatherMap) makeAPIRequest(ctx context.Context, url string, tracer trace.Tracer) ([]byte, error) {
 	spanCtx, span := tracer.Start(ctx, "call_owm_makeAPIRequest", trace.WithAttributes(attribute.Key("owm_MakeAPIRequest").String("call_owm_data_source")))
 	defer span.End()
 
+	if owm.Client == nil {
+		return nil, tracing.RecordError(span, errors.New("no http client configured"))
+	}
+
 	req, getErr := http.NewRequest("GET", url, nil)
 	if getErr != nil {
-		return nil, getErr
+		return nil, tracing.RecordError(span, getErr)
+	}
+
+	// every attempt count against the quota, retries of a 429 included
+	doCtx := spanCtx
+	if owm.limiter != nil {
+		doCtx = libhttp.WithAttemptGate(spanCtx, owm.limiter.acquire)
 	}
 
-	res, err := libhttp.Do(spanCtx, req, tracer)
+	res, err := owm.Client.Do(doCtx, req, tracer)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 
 	// defer the closing of the res body
@@ -148,11 +220,15 @@ func makeAPIRequest(ctx context.Context, url string, tracer trace.Tracer) ([]byt
 	// read the http response body into a byte stream
 	body, readErr := ioutil.ReadAll(res.Body)
 	if readErr != nil {
-		return nil, readErr
+		return nil, tracing.RecordError(span, readErr)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		retryAfter, _ := libhttp.RetryAfter(res)
+		return nil, tracing.RecordError(span, parseAPIError(res.StatusCode, body, retryAfter))
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "makeAPIRequestSuccessfull")
+	span.SetStatus(codes.Ok, "makeAPIRequestSuccessfull")
 	return body, nil
 }
 
@@ -167,24 +243,23 @@ func (owm *OpenWeatherMap) CurrentWeatherFromCity(ctx context.Context, city stri
 
 	if owm.APIKEY == "" {
 		// No API keys present, return error
-		return nil, errors.New("no api keys present")
+		return nil, tracing.RecordError(span, errors.New("no api keys present"))
 	}
-	url := fmt.Sprintf("http://%s/data/2.5/weather?q=%s&units=metric&APPID=%s", APIURL, city, owm.APIKEY)
+	url := owm.buildURL("weather", cityQuery(city))
 
-	body, err := makeAPIRequest(spanCtx, url, tracer)
+	body, err := owm.makeAPIRequest(spanCtx, url, tracer)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 	var cwr CurrentWeatherResponse
 
 	// unmarshal the byte stream into a Go data type
 	jsonErr := json.Unmarshal(body, &cwr)
 	if jsonErr != nil {
-		return nil, jsonErr
+		return nil, tracing.RecordError(span, jsonErr)
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "requestCurrentWeatherFromCitySuccessfull")
+	span.SetStatus(codes.Ok, "requestCurrentWeatherFromCitySuccessfull")
 	return &cwr, nil
 }
 
@@ -199,14 +274,14 @@ func (owm *OpenWeatherMap) CurrentWeatherFromCoordinates(ctx context.Context, la
 
 	if owm.APIKEY == "" {
 		// No API keys present, return error
-		return nil, errors.New("no api keys present")
+		return nil, tracing.RecordError(span, errors.New("no api keys present"))
 	}
 
-	url := fmt.Sprintf("http://%s/data/2.5/weather?lat=%f&lon=%f&units=metric&APPID=%s", APIURL, lat, long, owm.APIKEY)
+	url := owm.buildURL("weather", fmt.Sprintf("lat=%f&lon=%f", lat, long))
 
-	body, err := makeAPIRequest(spanCtx, url, tracer)
+	body, err := owm.makeAPIRequest(spanCtx, url, tracer)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 
 	var cwr CurrentWeatherResponse
@@ -214,11 +289,10 @@ func (owm *OpenWeatherMap) CurrentWeatherFromCoordinates(ctx context.Context, la
 	// unmarshal the byte stream into a Go data type
 	jsonErr := json.Unmarshal(body, &cwr)
 	if jsonErr != nil {
-		return nil, jsonErr
+		return nil, tracing.RecordError(span, jsonErr)
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "requestCurrentWeatherFromCoordinatesSuccessfull")
+	span.SetStatus(codes.Ok, "requestCurrentWeatherFromCoordinatesSuccessfull")
 	return &cwr, nil
 }
 
@@ -231,24 +305,23 @@ func (owm *OpenWeatherMap) CurrentWeatherFromZip(ctx context.Context, zip int, t
 
 	if owm.APIKEY == "" {
 		// No API keys present, return error
-		return nil, errors.New("no api keys present")
+		return nil, tracing.RecordError(span, errors.New("no api keys present"))
 	}
-	url := fmt.Sprintf("http://%s/data/2.5/weather?zip=%d&units=metric&APPID=%s", APIURL, zip, owm.APIKEY)
+	url := owm.buildURL("weather", fmt.Sprintf("zip=%d", zip))
 
-	body, err := makeAPIRequest(ctx, url, tracer)
+	body, err := owm.makeAPIRequest(spanCtx, url, tracer)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 	var cwr CurrentWeatherResponse
 
 	// unmarshal the byte stream into a Go data type
 	jsonErr := json.Unmarshal(body, &cwr)
 	if jsonErr != nil {
-		return nil, jsonErr
+		return nil, tracing.RecordError(span, jsonErr)
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "requestCurrentWeatherFromZipSuccessful")
+	span.SetStatus(codes.Ok, "requestCurrentWeatherFromZipSuccessful")
 	return &cwr, nil
 }
 
@@ -261,23 +334,22 @@ func (owm *OpenWeatherMap) CurrentWeatherFromCityId(ctx context.Context, id int,
 
 	if owm.APIKEY == "" {
 		// No API keys present, return error
-		return nil, errors.New("no api keys present")
+		return nil, tracing.RecordError(span, errors.New("no api keys present"))
 	}
-	url := fmt.Sprintf("http://%s/data/2.5/weather?id=%d&units=metric&APPID=%s", APIURL, id, owm.APIKEY)
+	url := owm.buildURL("weather", fmt.Sprintf("id=%d", id))
 
-	body, err := makeAPIRequest(spanCtx, url, tracer)
+	body, err := owm.makeAPIRequest(spanCtx, url, tracer)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 	var cwr CurrentWeatherResponse
 
 	// unmarshal the byte stream into a Go data type
 	jsonErr := json.Unmarshal(body, &cwr)
 	if jsonErr != nil {
-		return nil, jsonErr
+		return nil, tracing.RecordError(span, jsonErr)
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "requestCurrentWeatherFromCityIDSuccessful")
+	span.SetStatus(codes.Ok, "requestCurrentWeatherFromCityIDSuccessful")
 	return &cwr, nil
 }
@@ -0,0 +1,69 @@
+package owm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testdata/current_weather.json is the sample response of the current weather API documentation
+func TestCurrentWeatherResponseRoundTrip(t *testing.T) {
+	raw, err := ioutil.ReadFile(filepath.Join("testdata", "current_weather.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cwr CurrentWeatherResponse
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// every field sent by openweathermap must be modelled
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cwr); err != nil {
+		t.Fatal(err)
+	}
+
+	if cwr.Rain == nil || cwr.Rain.OneHr != 3.16 {
+		t.Errorf("rain = %+v, want 3.16mm over 1h", cwr.Rain)
+	}
+	if cwr.Snow != nil {
+		t.Errorf("snow = %+v, want nil", cwr.Snow)
+	}
+	if cwr.Sys.Country != "IT" || cwr.Timezone != 7200 || cwr.Wind.Gust != 1.18 || cwr.Main.GrndLevel != 933 {
+		t.Errorf("decoded %+v", cwr)
+	}
+
+	encoded, err := json.Marshal(cwr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got map[string]interface{}
+	if err := json.Unmarshal(raw, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the response\ngot  %s\nwant %s", encoded, raw)
+	}
+}
+
+func TestCurrentWeatherResponseWithoutPrecipitation(t *testing.T) {
+	encoded, err := json.Marshal(CurrentWeatherResponse{Name: "Depok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"rain", "snow"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("%s encoded although none was reported: %s", key, encoded)
+		}
+	}
+}
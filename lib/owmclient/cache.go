@@ -0,0 +1,223 @@
+package owmclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/cache"
+	"weather/lib/metrics"
+)
+
+const (
+	cacheTTLEnv        = "WEATHER_CACHE_TTL"
+	cacheMaxEntriesEnv = "WEATHER_CACHE_MAX_ENTRIES"
+	cacheServeStaleEnv = "WEATHER_CACHE_SERVE_STALE"
+	cacheMaxStaleEnv   = "WEATHER_CACHE_MAX_STALE"
+
+	cacheName = "weather"
+
+	cacheResultKey = attribute.Key("weather.cache")
+	cacheAgeKey    = attribute.Key("weather.cache.age_ms")
+
+	cacheHit   = "hit"
+	cacheMiss  = "miss"
+	cacheStale = "stale"
+)
+
+// CacheConfig tune a CachedProvider
+type CacheConfig struct {
+	// TTL is how long an answer is served without asking the backend
+	TTL time.Duration
+	// MaxEntries bound the cache size, least recently used answers are evicted first. 0 disable the cache
+	MaxEntries int
+	// ServeStale answer with an expired entry when the backend fails
+	ServeStale bool
+	// MaxStale is how long after TTL an expired entry may still be served
+	MaxStale time.Duration
+}
+
+// DefaultCacheConfig match the ten minutes refresh period of openweathermap data
+var DefaultCacheConfig = CacheConfig{
+	TTL:        10 * time.Minute,
+	MaxEntries: 1000,
+	ServeStale: false,
+	MaxStale:   time.Hour,
+}
+
+// CacheConfigFromEnv read the WEATHER_CACHE_* variables, unset variables keep DefaultCacheConfig
+func CacheConfigFromEnv() (CacheConfig, error) {
+	cfg := DefaultCacheConfig
+
+	if v := strings.TrimSpace(os.Getenv(cacheTTLEnv)); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid %s %q: must be a positive duration such as 10m", cacheTTLEnv, v)
+		}
+		cfg.TTL = d
+	}
+	if v := strings.TrimSpace(os.Getenv(cacheMaxEntriesEnv)); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid %s %q: must be a non negative integer", cacheMaxEntriesEnv, v)
+		}
+		cfg.MaxEntries = n
+	}
+	if v := strings.TrimSpace(os.Getenv(cacheServeStaleEnv)); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s %q: must be true or false", cacheServeStaleEnv, v)
+		}
+		cfg.ServeStale = b
+	}
+	if v := strings.TrimSpace(os.Getenv(cacheMaxStaleEnv)); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid %s %q: must be a non negative duration such as 1h", cacheMaxStaleEnv, v)
+		}
+		cfg.MaxStale = d
+	}
+
+	return cfg, nil
+}
+
+// CachedProvider answer from memory the lookups made again within the TTL.
+// Cached answers are shared between callers and must not be modified
+type CachedProvider struct {
+	WeatherProvider
+
+	cfg   CacheConfig
+	cache *cache.LRU
+	// prefix keep answers of different backends and settings apart
+	prefix string
+}
+
+func NewCachedProvider(provider WeatherProvider, cfg CacheConfig) *CachedProvider {
+	return &CachedProvider{
+		WeatherProvider: provider,
+		cfg:             cfg,
+		cache:           cache.New(cfg.MaxEntries),
+		prefix:          keyPrefix(provider),
+	}
+}
+
+func (p *CachedProvider) CurrentWeatherByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	value, err := p.lookup(ctx, p.key("current", cityKey(city)), func() (interface{}, error) {
+		return p.WeatherProvider.CurrentWeatherByCity(ctx, city, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedWeatherData), nil
+}
+
+func (p *CachedProvider) CurrentWeatherByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	value, err := p.lookup(ctx, p.key("current", coordinatesKey(lat, long)), func() (interface{}, error) {
+		return p.WeatherProvider.CurrentWeatherByCoordinates(ctx, lat, long, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedWeatherData), nil
+}
+
+func (p *CachedProvider) ForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedForecastData, error) {
+	value, err := p.lookup(ctx, p.key("forecast", cityKey(city)), func() (interface{}, error) {
+		return p.WeatherProvider.ForecastByCity(ctx, city, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedForecastData), nil
+}
+
+func (p *CachedProvider) ForecastByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedForecastData, error) {
+	value, err := p.lookup(ctx, p.key("forecast", coordinatesKey(lat, long)), func() (interface{}, error) {
+		return p.WeatherProvider.ForecastByCoordinates(ctx, lat, long, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedForecastData), nil
+}
+
+// lookup return the cached answer for key while fresh, otherwise fetch and cache a new one.
+// The outcome is recorded on the span of ctx and in the cache metrics
+func (p *CachedProvider) lookup(ctx context.Context, key string, fetch func() (interface{}, error)) (interface{}, error) {
+	// a cache.LRU of size 0 is unbounded, while a CacheConfig of size 0 disable caching
+	if p.cfg.MaxEntries == 0 {
+		return fetch()
+	}
+	span := trace.SpanFromContext(ctx)
+
+	cached, age, found := p.cache.Get(key)
+	if found && age < p.cfg.TTL {
+		p.record(ctx, span, cacheHit, age)
+		return cached, nil
+	}
+
+	value, err := fetch()
+	if err == nil {
+		metrics.RecordCacheEvictions(ctx, cacheName, p.cache.Add(key, value))
+		p.record(ctx, span, cacheMiss, 0)
+		return value, nil
+	}
+
+	if found && p.cfg.ServeStale && age < p.cfg.TTL+p.cfg.MaxStale {
+		span.AddEvent("serving stale cache entry", trace.WithAttributes(attribute.String("error", err.Error())))
+		p.record(ctx, span, cacheStale, age)
+		return cached, nil
+	}
+
+	p.record(ctx, span, cacheMiss, 0)
+	return nil, err
+}
+
+func (p *CachedProvider) record(ctx context.Context, span trace.Span, result string, age time.Duration) {
+	span.SetAttributes(cacheResultKey.String(result))
+	if result != cacheMiss {
+		span.SetAttributes(cacheAgeKey.Int64(age.Milliseconds()))
+	}
+	metrics.RecordCacheLookup(ctx, cacheName, result)
+}
+
+func (p *CachedProvider) key(kind string, location string) string {
+	return lookupKey(p.prefix, kind, location)
+}
+
+// keyPrefix describe the backend behind provider and the options changing its answers,
+// such as units or language, so answers of different backends and settings are kept apart
+func keyPrefix(provider WeatherProvider) string {
+	switch p := provider.(type) {
+	case *CachedProvider:
+		return keyPrefix(p.WeatherProvider)
+	case *CoalescingProvider:
+		return keyPrefix(p.WeatherProvider)
+	case *OWMProvider:
+		return p.Name() + "?units=" + string(p.owm.Units()) + "&lang=" + p.owm.Lang()
+	case *OpenMeteoProvider:
+		return p.Name() + "?temperature_unit=" + string(p.client.TemperatureUnit())
+	}
+	return provider.Name()
+}
+
+// lookupKey identify a lookup, prefix keep answers of different backends and settings apart
+func lookupKey(prefix string, kind string, location string) string {
+	return prefix + "|" + kind + "|" + location
+}
+
+// cityKey fold case and spacing so "New  York" and "new york" share an entry
+func cityKey(city string) string {
+	return "city=" + strings.ToLower(strings.Join(strings.Fields(city), " "))
+}
+
+// coordinatesKey round to about 10 meters so nearly identical coordinates share an entry
+func coordinatesKey(lat, long float64) string {
+	return fmt.Sprintf("coord=%.4f,%.4f", lat, long)
+}
@@ -0,0 +1,112 @@
+package owmclient
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errBackend = errors.New("backend down")
+
+// countingProvider count the backend calls and fail them while failing is set
+type countingProvider struct {
+	calls   int32
+	failing int32
+}
+
+func (p *countingProvider) Name() string {
+	return "counting"
+}
+
+func (p *countingProvider) CurrentWeatherByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	n := atomic.AddInt32(&p.calls, 1)
+	if atomic.LoadInt32(&p.failing) == 1 {
+		return nil, errBackend
+	}
+	return &StrippedWeatherData{Condition: city, Humidity: int(n)}, nil
+}
+
+func (p *countingProvider) CurrentWeatherByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	return p.CurrentWeatherByCity(ctx, "", tracer)
+}
+
+func (p *countingProvider) ForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedForecastData, error) {
+	return nil, nil
+}
+
+func (p *countingProvider) ForecastByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedForecastData, error) {
+	return nil, nil
+}
+
+func TestCachedProviderLookup(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+
+	tests := []struct {
+		name string
+		cfg  CacheConfig
+		// wait between both lookups
+		wait time.Duration
+		// the backend fail the second lookup
+		fail bool
+
+		calls int32
+		// answer of the second lookup, from the first (1) or the second (2) backend call
+		humidity int
+		wantErr  error
+	}{
+		{"fresh entry served from cache", CacheConfig{TTL: time.Hour, MaxEntries: 10}, 0, false, 1, 1, nil},
+		{"expired entry fetched again", CacheConfig{TTL: ttl, MaxEntries: 10}, 2 * ttl, false, 2, 2, nil},
+		{"stale entry served on failure", CacheConfig{TTL: ttl, MaxEntries: 10, ServeStale: true, MaxStale: time.Hour}, 2 * ttl, true, 2, 1, nil},
+		{"stale entry not served past MaxStale", CacheConfig{TTL: ttl, MaxEntries: 10, ServeStale: true, MaxStale: ttl}, 3 * ttl, true, 2, 0, errBackend},
+		{"stale entry not served unless enabled", CacheConfig{TTL: ttl, MaxEntries: 10, MaxStale: time.Hour}, 2 * ttl, true, 2, 0, errBackend},
+		{"fresh entry served while the backend fail", CacheConfig{TTL: time.Hour, MaxEntries: 10}, 0, true, 1, 1, nil},
+		{"cache disabled by MaxEntries 0", CacheConfig{TTL: time.Hour, MaxEntries: 0}, 0, false, 2, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &countingProvider{}
+			p := NewCachedProvider(backend, tt.cfg)
+
+			if _, err := p.CurrentWeatherByCity(context.Background(), "depok", tracer); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+			if tt.fail {
+				atomic.StoreInt32(&backend.failing, 1)
+			}
+
+			got, err := p.CurrentWeatherByCity(context.Background(), "depok", tracer)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Humidity != tt.humidity {
+				t.Errorf("answer of backend call %d, want %d", got.Humidity, tt.humidity)
+			}
+			if calls := atomic.LoadInt32(&backend.calls); calls != tt.calls {
+				t.Errorf("backend called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestCachedProviderEviction(t *testing.T) {
+	backend := &countingProvider{}
+	p := NewCachedProvider(backend, CacheConfig{TTL: time.Hour, MaxEntries: 2})
+
+	for _, city := range []string{"depok", "bogor", "depok", "jakarta", "bogor", "New  York", "new york"} {
+		if _, err := p.CurrentWeatherByCity(context.Background(), city, tracer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// depok and bogor are cached, depok is found again, jakarta evict bogor which is fetched again
+	// and evict depok, then both spellings of new york share one entry
+	if calls := atomic.LoadInt32(&backend.calls); calls != 5 {
+		t.Errorf("backend called %d times, want 5", calls)
+	}
+}
@@ -0,0 +1,196 @@
+package owmclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/tracing"
+)
+
+const (
+	coalesceEnv = "WEATHER_COALESCE"
+
+	coalescedKey        = attribute.Key("weather.coalesced")
+	coalescedWaitersKey = attribute.Key("weather.coalesced_waiters")
+
+	// maxCallDuration bound a shared call, which outlive the deadline of the request leading it
+	maxCallDuration = 30 * time.Second
+)
+
+// CoalescingProvider share one backend call between concurrent identical lookups.
+// The first caller lead the call, the others wait in a span linked to the leader span.
+// Shared answers must not be modified
+type CoalescingProvider struct {
+	WeatherProvider
+
+	prefix string
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call is a backend call in flight and its outcome once done is closed
+type call struct {
+	done    chan struct{}
+	leader  trace.SpanContext
+	waiters int
+	// callers still waiting for the outcome, the call is canceled when the last one leaves
+	callers int
+	cancel  context.CancelFunc
+
+	value interface{}
+	err   error
+}
+
+func NewCoalescingProvider(provider WeatherProvider) *CoalescingProvider {
+	return &CoalescingProvider{
+		WeatherProvider: provider,
+		prefix:          keyPrefix(provider),
+		calls:           make(map[string]*call),
+	}
+}
+
+func (p *CoalescingProvider) CurrentWeatherByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	value, err := p.do(ctx, lookupKey(p.prefix, "current", cityKey(city)), tracer, func(ctx context.Context) (interface{}, error) {
+		return p.WeatherProvider.CurrentWeatherByCity(ctx, city, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedWeatherData), nil
+}
+
+func (p *CoalescingProvider) CurrentWeatherByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	value, err := p.do(ctx, lookupKey(p.prefix, "current", coordinatesKey(lat, long)), tracer, func(ctx context.Context) (interface{}, error) {
+		return p.WeatherProvider.CurrentWeatherByCoordinates(ctx, lat, long, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedWeatherData), nil
+}
+
+func (p *CoalescingProvider) ForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedForecastData, error) {
+	value, err := p.do(ctx, lookupKey(p.prefix, "forecast", cityKey(city)), tracer, func(ctx context.Context) (interface{}, error) {
+		return p.WeatherProvider.ForecastByCity(ctx, city, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedForecastData), nil
+}
+
+func (p *CoalescingProvider) ForecastByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedForecastData, error) {
+	value, err := p.do(ctx, lookupKey(p.prefix, "forecast", coordinatesKey(lat, long)), tracer, func(ctx context.Context) (interface{}, error) {
+		return p.WeatherProvider.ForecastByCoordinates(ctx, lat, long, tracer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*StrippedForecastData), nil
+}
+
+// do run fetch once for all the concurrent callers of key. The call is detached from
+// the leader cancellation so a leader giving up does not fail the callers waiting on it.
+// It is canceled once every caller gave up, and bounded by maxCallDuration
+func (p *CoalescingProvider) do(ctx context.Context, key string, tracer trace.Tracer, fetch func(context.Context) (interface{}, error)) (interface{}, error) {
+	p.mu.Lock()
+	if c, ok := p.calls[key]; ok {
+		c.waiters++
+		c.callers++
+		p.mu.Unlock()
+		return p.wait(ctx, key, c, tracer)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	callCtx, cancel := context.WithTimeout(detached{ctx}, maxCallDuration)
+	c := &call{done: make(chan struct{}), leader: span.SpanContext(), callers: 1, cancel: cancel}
+	p.calls[key] = c
+	p.mu.Unlock()
+
+	go func() {
+		c.value, c.err = fetch(callCtx)
+		cancel()
+
+		p.mu.Lock()
+		p.forget(key, c)
+		waiters := c.waiters
+		p.mu.Unlock()
+
+		span.SetAttributes(coalescedWaitersKey.Int(waiters))
+		close(c.done)
+	}()
+
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		p.leave(key, c)
+		return nil, ctx.Err()
+	}
+}
+
+// wait for the outcome of the call in a span linked to the span of the leader
+func (p *CoalescingProvider) wait(ctx context.Context, key string, c *call, tracer trace.Tracer) (interface{}, error) {
+	trace.SpanFromContext(ctx).SetAttributes(coalescedKey.Bool(true))
+
+	_, span := tracer.Start(ctx, "call_owmclient_coalesced",
+		trace.WithLinks(trace.Link{SpanContext: c.leader}),
+		trace.WithAttributes(coalescedKey.Bool(true)),
+	)
+	defer span.End()
+
+	select {
+	case <-c.done:
+		if c.err != nil {
+			return nil, tracing.RecordError(span, c.err)
+		}
+		span.SetStatus(codes.Ok, "coalescedCallSuccessfull")
+		return c.value, nil
+	case <-ctx.Done():
+		p.leave(key, c)
+		return nil, tracing.RecordError(span, ctx.Err())
+	}
+}
+
+// leave record a caller giving up, the last one cancel the call nobody wait for anymore
+func (p *CoalescingProvider) leave(key string, c *call) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c.callers--
+	if c.callers == 0 {
+		c.cancel()
+		// later callers start a fresh call instead of joining the canceled one
+		p.forget(key, c)
+	}
+}
+
+// forget remove c from the calls in flight, unless a newer call already replaced it
+func (p *CoalescingProvider) forget(key string, c *call) {
+	if p.calls[key] == c {
+		delete(p.calls, key)
+	}
+}
+
+// detached keep the values of a context, such as its span, without its cancellation and deadline
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
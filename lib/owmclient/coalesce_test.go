@@ -0,0 +1,133 @@
+package owmclient
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// blockingProvider answer once release is closed, or fail when the call is canceled
+type blockingProvider struct {
+	calls    int32
+	release  chan struct{}
+	canceled chan struct{}
+}
+
+func newBlockingProvider() *blockingProvider {
+	return &blockingProvider{release: make(chan struct{}), canceled: make(chan struct{})}
+}
+
+func (p *blockingProvider) Name() string {
+	return "blocking"
+}
+
+func (p *blockingProvider) CurrentWeatherByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	atomic.AddInt32(&p.calls, 1)
+	select {
+	case <-p.release:
+		return &StrippedWeatherData{Condition: "Clear"}, nil
+	case <-ctx.Done():
+		close(p.canceled)
+		return nil, ctx.Err()
+	}
+}
+
+func (p *blockingProvider) CurrentWeatherByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	return p.CurrentWeatherByCity(ctx, "", tracer)
+}
+
+func (p *blockingProvider) ForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedForecastData, error) {
+	return nil, nil
+}
+
+func (p *blockingProvider) ForecastByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedForecastData, error) {
+	return nil, nil
+}
+
+func TestCoalescingSharesOneCall(t *testing.T) {
+	backend := newBlockingProvider()
+	provider := NewCoalescingProvider(backend)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			swd, err := provider.CurrentWeatherByCity(context.Background(), "Depok", tracer)
+			if err != nil || swd.Condition != "Clear" {
+				t.Errorf("got %+v, %v", swd, err)
+			}
+		}()
+	}
+
+	waitFor(t, func() bool {
+		provider.mu.Lock()
+		defer provider.mu.Unlock()
+		c := provider.calls[lookupKey(provider.prefix, "current", cityKey("depok"))]
+		return c != nil && c.callers == 5
+	})
+	close(backend.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&backend.calls); calls != 1 {
+		t.Errorf("backend called %d times, want 1", calls)
+	}
+}
+
+func TestCoalescingCancelsAbandonedCall(t *testing.T) {
+	backend := newBlockingProvider()
+	provider := NewCoalescingProvider(backend)
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := provider.CurrentWeatherByCity(leaderCtx, "Depok", tracer)
+		errs <- err
+	}()
+	waitFor(t, func() bool { return atomic.LoadInt32(&backend.calls) == 1 })
+	go func() {
+		_, err := provider.CurrentWeatherByCity(waiterCtx, "Depok", tracer)
+		errs <- err
+	}()
+	waitFor(t, func() bool {
+		provider.mu.Lock()
+		defer provider.mu.Unlock()
+		for _, c := range provider.calls {
+			return c.callers == 2
+		}
+		return false
+	})
+
+	// the leader leaving alone keep the call running for the waiter
+	cancelLeader()
+	<-errs
+	select {
+	case <-backend.canceled:
+		t.Fatal("call canceled while a caller still waits")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancelWaiter()
+	<-errs
+	select {
+	case <-backend.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("call still running after every caller gave up")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
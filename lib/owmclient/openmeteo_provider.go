@@ -0,0 +1,119 @@
+package owmclient
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/openmeteo"
+)
+
+const (
+	// stepHours match the 3 hours steps of openweathermap forecasts
+	stepHours = 3
+	// snowWaterRatio turn centimetres of fresh snow into millimetres of water, as openweathermap report snow.
+	// open-meteo document 7 cm of snow as 10 mm of water
+	snowWaterRatio = 10.0 / 7.0
+
+	openMeteoTimeLayout = "2006-01-02T15:04"
+	forecastTimeLayout  = "2006-01-02 15:04:05"
+)
+
+// OpenMeteoProvider serve weather data from open-meteo, cities are resolved by its geocoding API
+type OpenMeteoProvider struct {
+	client *openmeteo.Client
+}
+
+func NewOpenMeteoProvider(client *openmeteo.Client) *OpenMeteoProvider {
+	return &OpenMeteoProvider{client: client}
+}
+
+func (p *OpenMeteoProvider) Name() string {
+	return "openmeteo"
+}
+
+func (p *OpenMeteoProvider) CurrentWeatherByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	location, err := p.client.Geocode(ctx, city, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return p.CurrentWeatherByCoordinates(ctx, location.Latitude, location.Longitude, tracer)
+}
+
+func (p *OpenMeteoProvider) CurrentWeatherByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	fr, err := p.client.Current(ctx, lat, long, tracer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StrippedWeatherData{
+		Condition:   openmeteo.Condition(fr.Current.WeatherCode),
+		Temperature: fr.Current.Temperature,
+		Humidity:    fr.Current.RelativeHumidity,
+	}, nil
+}
+
+func (p *OpenMeteoProvider) ForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedForecastData, error) {
+	location, err := p.client.Geocode(ctx, city, tracer)
+	if err != nil {
+		return nil, err
+	}
+
+	sfd, err := p.ForecastByCoordinates(ctx, location.Latitude, location.Longitude, tracer)
+	if err != nil {
+		return nil, err
+	}
+	sfd.City = location.Name
+	return sfd, nil
+}
+
+// ForecastByCoordinates fold the hourly forecast into 3 hours steps,
+// keeping the first hour values and the highest precipitation probability and total volumes of the step
+func (p *OpenMeteoProvider) ForecastByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedForecastData, error) {
+	fr, err := p.client.Forecast(ctx, lat, long, tracer)
+	if err != nil {
+		return nil, err
+	}
+
+	hourly := fr.Hourly
+	sfd := &StrippedForecastData{
+		Steps: make([]StrippedForecastStep, 0, len(hourly.Time)/stepHours+1),
+	}
+	for i := 0; i < len(hourly.Time); i += stepHours {
+		step := StrippedForecastStep{
+			Time:        hourly.Time[i],
+			Condition:   openmeteo.Condition(intAt(hourly.WeatherCode, i)),
+			Temperature: floatAt(hourly.Temperature, i),
+			Humidity:    intAt(hourly.RelativeHumidity, i),
+		}
+		if t, err := time.Parse(openMeteoTimeLayout, hourly.Time[i]); err == nil {
+			step.Time = t.Format(forecastTimeLayout)
+		}
+
+		for h := i; h < i+stepHours && h < len(hourly.Time); h++ {
+			if pop := float64(intAt(hourly.PrecipitationProbability, h)) / 100; pop > step.PrecipitationProbability {
+				step.PrecipitationProbability = pop
+			}
+			step.Rain += floatAt(hourly.Rain, h)
+			step.Snow += floatAt(hourly.Snowfall, h) * snowWaterRatio
+		}
+		sfd.Steps = append(sfd.Steps, step)
+	}
+	return sfd, nil
+}
+
+// floatAt and intAt tolerate variables missing from the response
+func floatAt(values []float64, i int) float64 {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
+
+func intAt(values []int, i int) int {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package owmclient
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	openweathermap "weather/lib/owm"
+)
+
+// OWMProvider serve weather data from openweathermap
+type OWMProvider struct {
+	owm *openweathermap.OpenWeatherMap
+}
+
+func NewOWMProvider(owm *openweathermap.OpenWeatherMap) *OWMProvider {
+	return &OWMProvider{owm: owm}
+}
+
+func (p *OWMProvider) Name() string {
+	return "owm"
+}
+
+func (p *OWMProvider) CurrentWeatherByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	cwr, err := p.owm.CurrentWeatherFromCity(ctx, city, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return stripOWMCurrent(cwr), nil
+}
+
+func (p *OWMProvider) CurrentWeatherByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedWeatherData, error) {
+	cwr, err := p.owm.CurrentWeatherFromCoordinates(ctx, lat, long, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return stripOWMCurrent(cwr), nil
+}
+
+func (p *OWMProvider) ForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedForecastData, error) {
+	fr, err := p.owm.ForecastFromCity(ctx, city, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return stripOWMForecast(fr), nil
+}
+
+func (p *OWMProvider) ForecastByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedForecastData, error) {
+	fr, err := p.owm.ForecastFromCoordinates(ctx, lat, long, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return stripOWMForecast(fr), nil
+}
+
+func stripOWMCurrent(cwr *openweathermap.CurrentWeatherResponse) *StrippedWeatherData {
+	swd := &StrippedWeatherData{
+		Temperature: cwr.Main.Temp,
+		Humidity:    cwr.Main.Humidity,
+	}
+	if len(cwr.Weather) > 0 {
+		swd.Condition = cwr.Weather[0].Main
+	}
+	return swd
+}
+
+func stripOWMForecast(fr *openweathermap.ForecastResponse) *StrippedForecastData {
+	sfd := &StrippedForecastData{
+		City:  fr.City.Name,
+		Steps: make([]StrippedForecastStep, 0, len(fr.List)),
+	}
+	for _, item := range fr.List {
+		step := StrippedForecastStep{
+			Time:                     item.DTTxt,
+			Temperature:              item.Main.Temp,
+			Humidity:                 item.Main.Humidity,
+			PrecipitationProbability: item.Pop,
+		}
+		if item.Rain != nil {
+			step.Rain = item.Rain.ThreeHr
+		}
+		if item.Snow != nil {
+			step.Snow = item.Snow.ThreeHr
+		}
+		if len(item.Weather) > 0 {
+			step.Condition = item.Weather[0].Main
+		}
+		sfd.Steps = append(sfd.Steps, step)
+	}
+	return sfd
+}
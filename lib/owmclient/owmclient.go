@@ -2,8 +2,7 @@ package owmclient
 
 import (
 	"context"
-	"os"
-	openweathermap "weather/lib/owm"
+	"weather/lib/tracing"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -16,25 +15,55 @@ type StrippedWeatherData struct {
 	Humidity    int
 }
 
-func GetOwmForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
+// StrippedForecastStep is one 3 hours step of a five days forecast
+type StrippedForecastStep struct {
+	Time                     string
+	Condition                string
+	Temperature              float64
+	Humidity                 int
+	PrecipitationProbability float64
+	Rain                     float64
+	Snow                     float64
+}
+
+type StrippedForecastData struct {
+	City  string
+	Steps []StrippedForecastStep
+}
+
+// GetOwmForecastByCity return the current weather of a city from the configured provider
+func GetOwmForecastByCity(ctx context.Context, provider WeatherProvider, city string, tracer trace.Tracer) (*StrippedWeatherData, error) {
 
 	spanCtx, span := tracer.Start(ctx, "call_owmclient_GetOwmForecastByCity", trace.WithAttributes(attribute.Key("owmclient_GetOwmForecastByCity").String("get_owmforecast_by_city")))
 	defer span.End()
 
-	owm := openweathermap.OpenWeatherMap{APIKEY: os.Getenv("OWM_APP_ID")}
-	currentWeather, err := owm.CurrentWeatherFromCity(spanCtx, city, tracer)
+	span.SetAttributes(providerKey.String(provider.Name()))
+	swd, err := provider.CurrentWeatherByCity(spanCtx, city, tracer)
 
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 
-	swd := &StrippedWeatherData{
-		Condition:   currentWeather.Weather[0].Main,
-		Temperature: currentWeather.Main.Temp,
-		Humidity:    currentWeather.Main.Humidity,
+	span.SetStatus(codes.Ok, "GetOwmForecastByCitySuccessfull")
+	return swd, nil
+
+}
+
+// GetOwmFiveDayForecastByCity return the five days forecast of a city from the configured provider
+func GetOwmFiveDayForecastByCity(ctx context.Context, provider WeatherProvider, city string, tracer trace.Tracer) (*StrippedForecastData, error) {
+
+	spanCtx, span := tracer.Start(ctx, "call_owmclient_GetOwmFiveDayForecastByCity", trace.WithAttributes(attribute.Key("owmclient_GetOwmFiveDayForecastByCity").String("get_owm_five_day_forecast_by_city")))
+	defer span.End()
+
+	span.SetAttributes(providerKey.String(provider.Name()))
+	sfd, err := provider.ForecastByCity(spanCtx, city, tracer)
+
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
 	}
 
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "GetOwmForecastByCitySuccessfull")
-	return swd, nil
+	span.SetAttributes(attribute.Key("owmclient_forecast_steps").Int(len(sfd.Steps)))
+	span.SetStatus(codes.Ok, "GetOwmFiveDayForecastByCitySuccessfull")
+	return sfd, nil
 
 }
@@ -0,0 +1,82 @@
+package owmclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	libhttp "weather/lib/http"
+	"weather/lib/openmeteo"
+	openweathermap "weather/lib/owm"
+)
+
+const (
+	providerEnv = "WEATHER_PROVIDER"
+
+	providerKey = attribute.Key("weather.provider")
+)
+
+// WeatherProvider is a weather data backend, answering in the stripped models served by OWMService
+type WeatherProvider interface {
+	// Name identify the backend in spans and logs
+	Name() string
+	CurrentWeatherByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedWeatherData, error)
+	CurrentWeatherByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedWeatherData, error)
+	ForecastByCity(ctx context.Context, city string, tracer trace.Tracer) (*StrippedForecastData, error)
+	ForecastByCoordinates(ctx context.Context, lat, long float64, tracer trace.Tracer) (*StrippedForecastData, error)
+}
+
+// NewProviderFromEnv build the backend named by WEATHER_PROVIDER, owm or openmeteo, owm by default,
+// coalescing concurrent identical lookups unless WEATHER_COALESCE is false,
+// behind a cache configured by the WEATHER_CACHE_* variables.
+// Each backend read its own settings, see openweathermap.NewFromEnv and openmeteo.NewFromEnv
+func NewProviderFromEnv(client *libhttp.Client) (WeatherProvider, error) {
+	provider, err := backendFromEnv(client)
+	if err != nil {
+		return nil, err
+	}
+
+	coalesce := true
+	if v := strings.TrimSpace(os.Getenv(coalesceEnv)); v != "" {
+		if coalesce, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: must be true or false", coalesceEnv, v)
+		}
+	}
+	if coalesce {
+		provider = NewCoalescingProvider(provider)
+	}
+
+	cfg, err := CacheConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxEntries == 0 {
+		return provider, nil
+	}
+	return NewCachedProvider(provider, cfg), nil
+}
+
+func backendFromEnv(client *libhttp.Client) (WeatherProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv(providerEnv)))
+
+	switch name {
+	case "", "owm":
+		owm, err := openweathermap.NewFromEnv(client)
+		if err != nil {
+			return nil, err
+		}
+		return NewOWMProvider(owm), nil
+	case "openmeteo":
+		om, err := openmeteo.NewFromEnv(client)
+		if err != nil {
+			return nil, err
+		}
+		return NewOpenMeteoProvider(om), nil
+	}
+	return nil, fmt.Errorf("invalid %s %q: must be owm or openmeteo", providerEnv, name)
+}
@@ -0,0 +1,173 @@
+package owmclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	libhttp "weather/lib/http"
+	"weather/lib/openmeteo"
+	openweathermap "weather/lib/owm"
+)
+
+var tracer = trace.NewNoopTracerProvider().Tracer("test")
+
+func testClient() *libhttp.Client {
+	return libhttp.NewClient(libhttp.WithRetry(libhttp.RetryPolicy{MaxAttempts: 1}))
+}
+
+func TestOWMProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("APPID") != "key" || query.Get("units") != "imperial" || query.Get("lang") != "fr" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if query.Get("q") != "depok" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"cod":"404","message":"city not found"}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/data/2.5/weather":
+			w.Write([]byte(`{"weather":[{"id":800,"main":"Clear"}],"main":{"temp":86.2,"humidity":70},"name":"Depok","cod":200}`))
+		case "/data/2.5/forecast":
+			w.Write([]byte(`{"cnt":2,"list":[` +
+				`{"dt":1,"main":{"temp":70.1,"humidity":50},"weather":[{"main":"Rain"}],"pop":0.4,"rain":{"3h":1.25},"dt_txt":"2026-10-17 12:00:00"},` +
+				`{"dt":2,"main":{"temp":68,"humidity":60},"weather":[{"main":"Snow"}],"snow":{"3h":0.5},"dt_txt":"2026-10-17 15:00:00"}` +
+				`],"city":{"name":"Depok"}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	owm, err := openweathermap.New("key", testClient(),
+		openweathermap.WithBaseURL(strings.TrimPrefix(srv.URL, "http://")),
+		openweathermap.WithUnits(openweathermap.Imperial),
+		openweathermap.WithLang("fr"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewOWMProvider(owm)
+	ctx := context.Background()
+
+	current, err := provider.CurrentWeatherByCity(ctx, "depok", tracer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&StrippedWeatherData{Condition: "Clear", Temperature: 86.2, Humidity: 70}); !reflect.DeepEqual(current, want) {
+		t.Errorf("current = %+v, want %+v", current, want)
+	}
+
+	forecast, err := provider.ForecastByCity(ctx, "depok", tracer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &StrippedForecastData{City: "Depok", Steps: []StrippedForecastStep{
+		{Time: "2026-10-17 12:00:00", Condition: "Rain", Temperature: 70.1, Humidity: 50, PrecipitationProbability: 0.4, Rain: 1.25},
+		{Time: "2026-10-17 15:00:00", Condition: "Snow", Temperature: 68, Humidity: 60, Snow: 0.5},
+	}}
+	if !reflect.DeepEqual(forecast, want) {
+		t.Errorf("forecast = %+v, want %+v", forecast, want)
+	}
+
+	if _, err := provider.CurrentWeatherByCity(ctx, "nowhere", tracer); !errors.Is(err, openweathermap.ErrCityNotFound) {
+		t.Errorf("unknown city error = %v, want ErrCityNotFound", err)
+	}
+}
+
+func TestOpenMeteoProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		switch r.URL.Path {
+		case "/v1/search":
+			if query.Get("name") != "depok" {
+				w.Write([]byte(`{}`))
+				return
+			}
+			w.Write([]byte(`{"results":[{"id":1,"name":"Depok","latitude":-6.4,"longitude":106.8,"country_code":"ID"}]}`))
+		case "/v1/forecast":
+			if query.Get("temperature_unit") != "fahrenheit" || query.Get("latitude") != "-6.400000" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			if query.Get("current") != "" {
+				w.Write([]byte(`{"current":{"time":"2026-10-17T06:45","temperature_2m":85.1,"relative_humidity_2m":75,"weather_code":61}}`))
+				return
+			}
+			w.Write([]byte(`{"hourly":{` +
+				`"time":["2026-10-17T00:00","2026-10-17T01:00","2026-10-17T02:00","2026-10-17T03:00"],` +
+				`"temperature_2m":[77,75,73,72],"relative_humidity_2m":[80,81,82,83],` +
+				`"precipitation_probability":[10,50,null,5],"rain":[0.5,1.0,0,0],"snowfall":[0,0,0.7,0],"weather_code":[61,3,0,0]}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := openmeteo.New(testClient(),
+		openmeteo.WithForecastURL(srv.URL),
+		openmeteo.WithGeocodingURL(srv.URL),
+		openmeteo.WithTemperatureUnit(openmeteo.Fahrenheit),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewOpenMeteoProvider(client)
+	ctx := context.Background()
+
+	current, err := provider.CurrentWeatherByCity(ctx, "depok", tracer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&StrippedWeatherData{Condition: openmeteo.Condition(61), Temperature: 85.1, Humidity: 75}); !reflect.DeepEqual(current, want) {
+		t.Errorf("current = %+v, want %+v", current, want)
+	}
+
+	forecast, err := provider.ForecastByCity(ctx, "depok", tracer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// four hours fold into a full 3 hours step and a partial one
+	want := &StrippedForecastData{City: "Depok", Steps: []StrippedForecastStep{
+		{Time: "2026-10-17 00:00:00", Condition: openmeteo.Condition(61), Temperature: 77, Humidity: 80, PrecipitationProbability: 0.5, Rain: 1.5, Snow: 0.7 * snowWaterRatio},
+		{Time: "2026-10-17 03:00:00", Condition: openmeteo.Condition(0), Temperature: 72, Humidity: 83, PrecipitationProbability: 0.05},
+	}}
+	if !reflect.DeepEqual(forecast, want) {
+		t.Errorf("forecast = %+v, want %+v", forecast, want)
+	}
+
+	if _, err := provider.CurrentWeatherByCity(ctx, "nowhere", tracer); !errors.Is(err, openmeteo.ErrLocationNotFound) {
+		t.Errorf("unknown city error = %v, want ErrLocationNotFound", err)
+	}
+}
+
+func TestKeyPrefixKeepsSettingsApart(t *testing.T) {
+	metric, err := openweathermap.New("key", testClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	imperial, err := openweathermap.New("key", testClient(), openweathermap.WithUnits(openweathermap.Imperial))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := keyPrefix(NewCoalescingProvider(NewOWMProvider(metric)))
+	b := keyPrefix(NewOWMProvider(imperial))
+	if a == b {
+		t.Errorf("metric and imperial answers share the key prefix %q", a)
+	}
+	if !strings.HasPrefix(a, "owm?") {
+		t.Errorf("prefix %q does not name the backend", a)
+	}
+}
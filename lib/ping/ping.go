@@ -11,9 +11,10 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	libhttp "weather/lib/http"
+	"weather/lib/tracing"
 )
 
-func Ping(ctx context.Context, owmHost string, tracer trace.Tracer) (string, error) {
+func Ping(ctx context.Context, client *libhttp.Client, owmHost string, tracer trace.Tracer) (string, error) {
 
 	requestPath := "ping"
 
@@ -24,24 +25,24 @@ func Ping(ctx context.Context, owmHost string, tracer trace.Tracer) (string, err
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", err
+		return "", tracing.RecordError(span, err)
 	}
 
-	resp, err := libhttp.Do(spanCtx, req, tracer)
+	resp, err := client.Do(spanCtx, req, tracer)
 	if err != nil {
-		return "", err
+		return "", tracing.RecordError(span, err)
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return "", tracing.RecordError(span, err)
 	}
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("StatusCode: %d, Body: %s", resp.StatusCode, body)
+		return "", tracing.RecordError(span, fmt.Errorf("StatusCode: %d, Body: %s", resp.StatusCode, body))
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "requestPingSuccessfull")
+	span.SetStatus(codes.Ok, "requestPingSuccessfull")
 	return string(body), nil
 }
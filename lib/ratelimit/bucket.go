@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket, each call take a token and tokens come back at rate up to burst
+type Bucket struct {
+	// rate is in tokens per second
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket return a full bucket refilled with ratePerSecond tokens per second
+func NewBucket(ratePerSecond float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   ratePerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Reserve take a token and return how long to wait until it is actually available,
+// Cancel must be called when the call is not made after all
+func (b *Bucket) Reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return b.durationFor(-b.tokens)
+}
+
+// Allow take a token if one is available now, otherwise return how long until there is one
+func (b *Bucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, b.durationFor(1 - b.tokens)
+}
+
+// Cancel give back a token taken by a call which is not made
+func (b *Bucket) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// Burst return the size of the bucket
+func (b *Bucket) Burst() int {
+	return int(b.burst)
+}
+
+// Remaining return the tokens available now and how long until the bucket is full again
+func (b *Bucket) Remaining() (int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens <= 0 {
+		return 0, b.durationFor(b.burst - b.tokens)
+	}
+	return int(b.tokens), b.durationFor(b.burst - b.tokens)
+}
+
+func (b *Bucket) refill() {
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+func (b *Bucket) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketAllow(t *testing.T) {
+	b := NewBucket(10, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.Allow(); !ok {
+			t.Fatalf("call %d within the burst refused", i)
+		}
+	}
+	ok, retryAfter := b.Allow()
+	if ok {
+		t.Fatal("call over the burst allowed")
+	}
+	if retryAfter <= 0 || retryAfter > 100*time.Millisecond {
+		t.Errorf("retry after %s, want at most the 100ms of one token", retryAfter)
+	}
+
+	time.Sleep(retryAfter + 10*time.Millisecond)
+	if ok, _ := b.Allow(); !ok {
+		t.Error("call refused once a token came back")
+	}
+}
+
+func TestBucketReserve(t *testing.T) {
+	b := NewBucket(10, 1)
+
+	if delay := b.Reserve(); delay != 0 {
+		t.Fatalf("first reservation wait %s, want 0", delay)
+	}
+	first := b.Reserve()
+	second := b.Reserve()
+	if first <= 0 || second <= first {
+		t.Errorf("reservations wait %s then %s, want increasing waits", first, second)
+	}
+
+	// canceled reservations give their tokens back
+	b.Cancel()
+	if delay := b.Reserve(); delay > second {
+		t.Errorf("reservation after cancel wait %s, want at most %s", delay, second)
+	}
+}
+
+func TestBucketCancelCappedAtBurst(t *testing.T) {
+	b := NewBucket(1, 2)
+	b.Cancel()
+	b.Cancel()
+
+	if remaining, _ := b.Remaining(); remaining != 2 {
+		t.Errorf("remaining = %d, want the burst of 2", remaining)
+	}
+}
@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/auth"
+	"weather/lib/cache"
+)
+
+const (
+	throttledKey = attribute.Key("ratelimit.throttled")
+	keyTypeKey   = attribute.Key("ratelimit.key_type")
+)
+
+// Config of the per client limits
+type Config struct {
+	// PerMinute is the sustained rate allowed to each client. 0 disable limiting
+	PerMinute float64
+	// Burst is how many requests a client may send at once
+	Burst int
+	// MaxClients bound the number of tracked clients, the least recently seen are forgotten first
+	MaxClients int
+}
+
+// DefaultConfig is generous enough for interactive use while stopping a client looping on the API
+var DefaultConfig = Config{
+	PerMinute:  120,
+	Burst:      20,
+	MaxClients: 10000,
+}
+
+// DefaultAuthFailureConfig let a client mistype its key a few times while stopping key guessing
+var DefaultAuthFailureConfig = Config{
+	PerMinute:  10,
+	Burst:      5,
+	MaxClients: 10000,
+}
+
+// ConfigFromEnv read RATE_LIMIT_PER_MINUTE, RATE_LIMIT_BURST and RATE_LIMIT_MAX_CLIENTS,
+// unset variables keep DefaultConfig
+func ConfigFromEnv() (Config, error) {
+	return configFromEnv("RATE_LIMIT", DefaultConfig)
+}
+
+// AuthFailureConfigFromEnv read AUTH_FAILURE_LIMIT_PER_MINUTE, AUTH_FAILURE_LIMIT_BURST and
+// AUTH_FAILURE_LIMIT_MAX_CLIENTS, unset variables keep DefaultAuthFailureConfig
+func AuthFailureConfigFromEnv() (Config, error) {
+	return configFromEnv("AUTH_FAILURE_LIMIT", DefaultAuthFailureConfig)
+}
+
+func configFromEnv(prefix string, cfg Config) (Config, error) {
+	if v := strings.TrimSpace(os.Getenv(prefix + "_PER_MINUTE")); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 {
+			return cfg, fmt.Errorf("invalid %s_PER_MINUTE %q: must be a non negative number", prefix, v)
+		}
+		cfg.PerMinute = rate
+	}
+	if v := strings.TrimSpace(os.Getenv(prefix + "_BURST")); v != "" {
+		burst, err := strconv.Atoi(v)
+		if err != nil || burst < 1 {
+			return cfg, fmt.Errorf("invalid %s_BURST %q: must be a positive integer", prefix, v)
+		}
+		cfg.Burst = burst
+	}
+	if v := strings.TrimSpace(os.Getenv(prefix + "_MAX_CLIENTS")); v != "" {
+		clients, err := strconv.Atoi(v)
+		if err != nil || clients < 1 {
+			return cfg, fmt.Errorf("invalid %s_MAX_CLIENTS %q: must be a positive integer", prefix, v)
+		}
+		cfg.MaxClients = clients
+	}
+
+	return cfg, nil
+}
+
+// Limiter keep one token bucket per client, identified by its authenticated ID or else by address
+type Limiter struct {
+	cfg Config
+
+	mu      sync.Mutex
+	buckets *cache.LRU
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		buckets: cache.New(cfg.MaxClients),
+	}
+}
+
+// Middleware answer 429 with Retry-After to clients over their limit, and tell every client
+// its limit in X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset.
+// It must run after realip so clients behind the gateway are told apart,
+// and after auth.Authenticator.Middleware to limit each authenticated client as a whole
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l.cfg.PerMinute <= 0 {
+		return next
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		key, keyType := clientKey(r)
+		bucket := l.bucket(key)
+
+		allowed, retryAfter := bucket.Allow()
+		remaining, reset := bucket.Remaining()
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(bucket.Burst()))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(reset)))
+
+		if !allowed {
+			throttle(w, r, "rate limited", keyType, retryAfter)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// FailuresMiddleware answer 429 with Retry-After to addresses whose credentials auth.Authenticator.Middleware
+// rejected too many times, before checking them again, so keys and tokens cannot be guessed at full speed.
+// Only rejected attempts use up the limit, a 401 written by a handler does not.
+// It must run after realip and before auth.Authenticator.Middleware
+func (l *Limiter) FailuresMiddleware(next http.Handler) http.Handler {
+	if l.cfg.PerMinute <= 0 {
+		return next
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		bucket := l.bucket(addressKey(r))
+
+		// the attempt may fail, take its token now so concurrent attempts cannot overrun the limit
+		allowed, retryAfter := bucket.Allow()
+		if !allowed {
+			throttle(w, r, "authentication failures limited", "ip", retryAfter)
+			return
+		}
+
+		ctx, rejected := auth.TrackRejection(r.Context())
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		if !rejected() {
+			bucket.Cancel()
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// throttle answer 429 and record on the request span which limit was hit
+func throttle(w http.ResponseWriter, r *http.Request, event string, keyType string, retryAfter time.Duration) {
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(throttledKey.Bool(true), keyTypeKey.String(keyType))
+	span.AddEvent(event, trace.WithAttributes(
+		keyTypeKey.String(keyType),
+		attribute.Float64("retry_after_ms", float64(retryAfter)/float64(time.Millisecond)),
+	))
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+func (l *Limiter) bucket(key string) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, _, ok := l.buckets.Get(key); ok {
+		return b.(*Bucket)
+	}
+
+	b := NewBucket(l.cfg.PerMinute/60, l.cfg.Burst)
+	l.buckets.Add(key, b)
+	return b
+}
+
+// clientKey return the authenticated client of the request, or else its address, and which of both it is.
+// An unverified API key is never used, a caller could get a fresh bucket with each made up key
+func clientKey(r *http.Request) (string, string) {
+	if clientID, ok := auth.ClientID(r.Context()); ok {
+		return "client:" + clientID, "client_id"
+	}
+	return addressKey(r), "ip"
+}
+
+func addressKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// realip store the address without port
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
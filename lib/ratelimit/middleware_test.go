@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"weather/lib/auth"
+)
+
+func TestMiddlewareIgnoresUnverifiedAPIKeys(t *testing.T) {
+	limiter := New(Config{PerMinute: 1, Burst: 2, MaxClients: 100})
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/forecast/depok", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		// a new made up key on every request must not reset the limit
+		req.Header.Set("X-API-Key", fmt.Sprintf("random-%d", i))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		statuses = append(statuses, rec.Code)
+	}
+
+	if statuses[2] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v, want the third request throttled", statuses)
+	}
+	if n := limiter.buckets.Len(); n != 1 {
+		t.Errorf("tracked %d clients, want 1", n)
+	}
+}
+
+// sendWithKey serve a request from addr with key as API key and return its status
+func sendWithKey(handler http.Handler, addr string, key string) int {
+	req := httptest.NewRequest(http.MethodGet, "/forecast/depok", nil)
+	req.RemoteAddr = addr
+	req.Header.Set(auth.APIKeyHeader, key)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestFailuresMiddlewareCountsOnlyRejectedAttempts(t *testing.T) {
+	limiter := New(Config{PerMinute: 1, Burst: 2, MaxClients: 100})
+	authenticator := auth.New(map[string]string{"good": "acme"}, nil)
+	handler := limiter.FailuresMiddleware(authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	// successful attempts never use up the limit
+	for i := 0; i < 5; i++ {
+		if status := sendWithKey(handler, "203.0.113.7:4000", "good"); status != http.StatusOK {
+			t.Fatalf("authenticated request %d status = %d, want %d", i, status, http.StatusOK)
+		}
+	}
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		statuses = append(statuses, sendWithKey(handler, "203.0.113.7:4000", fmt.Sprintf("guess-%d", i)))
+	}
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", statuses, want)
+		}
+	}
+
+	// once throttled even a valid key waits, the address is not checked again before the limit resets
+	if status := sendWithKey(handler, "203.0.113.7:4000", "good"); status != http.StatusTooManyRequests {
+		t.Errorf("authenticated request after failures status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if status := sendWithKey(handler, "198.51.100.2:4000", "guess"); status != http.StatusUnauthorized {
+		t.Errorf("other address status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestFailuresMiddlewareIgnoresHandlerUnauthorized(t *testing.T) {
+	limiter := New(Config{PerMinute: 1, Burst: 2, MaxClients: 100})
+	// the handler answer 401 itself, e.g. for a credential problem of its own
+	downstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	for _, tt := range []struct {
+		name          string
+		authenticator *auth.Authenticator
+	}{
+		{"authentication disabled", auth.New(nil, nil)},
+		{"authenticated client", auth.New(map[string]string{"good": "acme"}, nil)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := limiter.FailuresMiddleware(tt.authenticator.Middleware(downstream))
+			for i := 0; i < 5; i++ {
+				if status := sendWithKey(handler, "203.0.113.7:4000", "good"); status != http.StatusUnauthorized {
+					t.Fatalf("request %d status = %d, want %d", i, status, http.StatusUnauthorized)
+				}
+			}
+		})
+	}
+}
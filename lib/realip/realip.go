@@ -0,0 +1,108 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const trustedProxiesEnv = "TRUSTED_PROXIES"
+
+// New return a middleware replacing the RemoteAddr of a request with the client address given by
+// X-Forwarded-For or X-Real-IP, only when the request comes from one of the trusted proxies.
+// Unlike middleware.RealIP, a client talking to the service directly can not choose its address
+func New(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if ip := clientIP(r, trusted); ip != "" {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// NewFromEnv trust the proxies listed in TRUSTED_PROXIES as comma separated CIDRs or addresses,
+// forwarded addresses are ignored when it is unset
+func NewFromEnv() (func(http.Handler) http.Handler, error) {
+	var trusted []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv(trustedProxiesEnv), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid %s %q: must be an address or a CIDR", trustedProxiesEnv, entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", trustedProxiesEnv, entry, err)
+		}
+		trusted = append(trusted, network)
+	}
+	return New(trusted), nil
+}
+
+// clientIP return the address of the client behind the trusted proxies, empty when r is not forwarded by one
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer := parseIP(r.RemoteAddr)
+	if peer == nil || !contains(trusted, peer) {
+		return ""
+	}
+
+	// every proxy append the address it received the request from, the rightmost
+	// untrusted one is the client, anything left of it may be forged
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return ""
+			}
+			if !contains(trusted, ip) {
+				return ip.String()
+			}
+		}
+		return ""
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// parseIP accept an address with or without port
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package realip
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	_, gateway, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct client can not forge its address", "203.0.113.7:4000", map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "1.2.3.4"}, "203.0.113.7:4000"},
+		{"gateway forwarded address", "10.0.0.2:4000", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "203.0.113.7"},
+		{"forged hops left of the client are ignored", "10.0.0.2:4000", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.0.0.3"}, "203.0.113.7"},
+		{"gateway real ip", "10.0.0.2:4000", map[string]string{"X-Real-IP": "203.0.113.7"}, "203.0.113.7"},
+		{"gateway without forwarded address", "10.0.0.2:4000", nil, "10.0.0.2:4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := New([]*net.IPNet{gateway})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/forecast/depok", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package redact
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const (
+	// Placeholder replace every redacted value
+	Placeholder = "REDACTED"
+
+	keysEnv = "REDACT_KEYS"
+)
+
+// sensitive hold the lower cased query parameters and headers never written to logs or spans,
+// REDACT_KEYS add comma separated names to the defaults
+var sensitive = keysFromEnv()
+
+func keysFromEnv() map[string]bool {
+	keys := map[string]bool{
+		"appid":         true,
+		"authorization": true,
+		"api_key":       true,
+	}
+	for _, name := range strings.Split(os.Getenv(keysEnv), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// IsSensitive report whether the query parameter or header name must be redacted
+func IsSensitive(name string) bool {
+	return sensitive[strings.ToLower(name)]
+}
+
+// URL return u as a string without credentials and with sensitive query values replaced
+func URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	if c.User != nil {
+		c.User = url.User(Placeholder)
+	}
+	c.RawQuery = Query(c.RawQuery)
+	return c.String()
+}
+
+// URLString redact a raw URL or request target such as /path?APPID=key
+func URLString(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		// keep nothing after the path rather than risk leaking the query
+		if i := strings.IndexByte(raw, '?'); i >= 0 {
+			return raw[:i+1] + Placeholder
+		}
+		return raw
+	}
+	return URL(u)
+}
+
+// Query replace the values of sensitive parameters of a raw query, keeping the parameters order
+func Query(rawQuery string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		rawName := param
+		if j := strings.IndexByte(param, '='); j >= 0 {
+			rawName = param[:j]
+		}
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		if IsSensitive(name) {
+			params[i] = rawName + "=" + Placeholder
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// Header return the values of a header, replaced when the header is sensitive
+func Header(name string, values []string) []string {
+	if !IsSensitive(name) {
+		return values
+	}
+	return []string{Placeholder}
+}
+
+// Attributes redact URL attributes and header attributes named http.<header>
+func Attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	for i, attr := range attrs {
+		switch {
+		case attr.Key == semconv.HTTPURLKey || attr.Key == semconv.HTTPTargetKey:
+			attrs[i] = attr.Key.String(URLString(attr.Value.AsString()))
+		case strings.HasPrefix(string(attr.Key), "http.") && IsSensitive(strings.TrimPrefix(string(attr.Key), "http.")):
+			attrs[i] = attr.Key.String(Placeholder)
+		}
+	}
+	return attrs
+}
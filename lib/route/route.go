@@ -0,0 +1,36 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+)
+
+// Unmatched label requests which match no chi route, keeping raw paths out of span names and metric labels
+const Unmatched = "unmatched"
+
+// Pattern return the chi route pattern matching r, e.g. /forecast/{city}
+// It can be called before routing happened, from a top level middleware
+func Pattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return Unmatched
+	}
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+	if rctx.Routes == nil {
+		return Unmatched
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return Unmatched
+	}
+	return tctx.RoutePattern()
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	libhttp "weather/lib/http"
+	"weather/lib/openmeteo"
+	openweathermap "weather/lib/owm"
+)
+
+// WriteError answer the status matching a failure of a downstream call, shared by both services
+// so they report failures alike
+func WriteError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	var apiErr *openweathermap.APIError
+	switch {
+	case errors.Is(err, libhttp.ErrCircuitOpen):
+		status = http.StatusServiceUnavailable
+	case errors.As(err, &apiErr) && errors.Is(err, openweathermap.ErrRateLimited):
+		status = http.StatusTooManyRequests
+		// only pass on a hint actually given, callers honour it
+		if apiErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+		}
+	case errors.Is(err, openmeteo.ErrRateLimited):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, openweathermap.ErrCityNotFound), errors.Is(err, openmeteo.ErrLocationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, openweathermap.ErrUnauthorized):
+		// a rejected OWM_APP_ID is a server side problem, the caller is not to blame
+		status = http.StatusBadGateway
+	case errors.Is(err, openweathermap.ErrUpstream), errors.Is(err, openmeteo.ErrUpstream):
+		status = http.StatusBadGateway
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	libhttp "weather/lib/http"
+	"weather/lib/openmeteo"
+	openweathermap "weather/lib/owm"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		retryAfter string
+	}{
+		{"circuit open", fmt.Errorf("api.openweathermap.org: %w", libhttp.ErrCircuitOpen), http.StatusServiceUnavailable, ""},
+		{"rate limited with hint", openweathermap.NewAPIError(http.StatusTooManyRequests, "", 1500*time.Millisecond), http.StatusTooManyRequests, "2"},
+		{"rate limited without hint", openweathermap.NewAPIError(http.StatusTooManyRequests, "", 0), http.StatusTooManyRequests, ""},
+		{"open-meteo rate limited", openmeteo.ErrRateLimited, http.StatusTooManyRequests, ""},
+		{"city not found", openweathermap.NewAPIError(http.StatusNotFound, "city not found", 0), http.StatusNotFound, ""},
+		{"location not found", openmeteo.ErrLocationNotFound, http.StatusNotFound, ""},
+		{"rejected OWM_APP_ID", openweathermap.NewAPIError(http.StatusUnauthorized, "invalid api key", 0), http.StatusBadGateway, ""},
+		{"upstream failure", openweathermap.NewAPIError(http.StatusInternalServerError, "", 0), http.StatusBadGateway, ""},
+		{"open-meteo failure", openmeteo.ErrUpstream, http.StatusBadGateway, ""},
+		{"other failure", errors.New("boom"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(rec, tt.err)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 
 	libhttp "weather/lib/http"
+	openweathermap "weather/lib/owm"
+	"weather/lib/tracing"
 )
 
 //RequestWeatherForecast represent return from owm service
@@ -21,7 +24,25 @@ type RequestWeatherForecast struct {
 	Humidity    int32   `json:"Humidity"`
 }
 
-func GetWeatherForecast(ctx context.Context, owmHost string, city string, tracer trace.Tracer) (*RequestWeatherForecast, error) {
+// RequestForecastStep represent one 3 hours step of the forecast returned by owm service
+type RequestForecastStep struct {
+	Time                     string  `json:"Time"`
+	Condition                string  `json:"Condition"`
+	Temperature              float64 `json:"Temperature"`
+	Humidity                 int32   `json:"Humidity"`
+	PrecipitationProbability float64 `json:"PrecipitationProbability"`
+	Rain                     float64 `json:"Rain"`
+	Snow                     float64 `json:"Snow"`
+}
+
+// RequestFiveDayForecast represent the five days forecast returned by owm service
+type RequestFiveDayForecast struct {
+	City  string                `json:"City"`
+	Steps []RequestForecastStep `json:"Steps"`
+}
+
+// GetWeatherForecast return the current weather of city from owm service
+func GetWeatherForecast(ctx context.Context, client *libhttp.Client, owmHost string, city string, tracer trace.Tracer) (*RequestWeatherForecast, error) {
 	//owmHost := os.Getenv("OWM_HOST")
 	requestPath := "getweather/owm"
 	requestParam := city
@@ -33,42 +54,81 @@ func GetWeatherForecast(ctx context.Context, owmHost string, city string, tracer
 
 	url := fmt.Sprintf("http://%s/%s/%s", owmHost, requestPath, requestParam)
 
-	req, _ := http.NewRequest("GET", url, nil)
+	body, err := callOWMService(spanCtx, client, url, tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	rwf, err := parseResponse(spanCtx, body, tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetStatus(codes.Ok, "requestGetWeatherForecastsSuccessfull")
+	return rwf, nil
+}
+
+// GetFiveDayForecast return the five days forecast of city from owm service
+func GetFiveDayForecast(ctx context.Context, client *libhttp.Client, owmHost string, city string, tracer trace.Tracer) (*RequestFiveDayForecast, error) {
+	spanCtx, span := tracer.Start(ctx, "call_GetFiveDayForecast", trace.WithAttributes(attribute.Key("GetFiveDayForecast").String("returning_your_city_forecast")))
+	defer span.End()
+
+	url := fmt.Sprintf("http://%s/getforecast/owm/%s", owmHost, city)
+
+	body, err := callOWMService(spanCtx, client, url, tracer)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	rfd := &RequestFiveDayForecast{}
+	if err := json.Unmarshal(body, rfd); err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	span.SetAttributes(attribute.Key("forecast_steps").Int(len(rfd.Steps)))
+	span.SetStatus(codes.Ok, "requestGetFiveDayForecastSuccessfull")
+	return rfd, nil
+}
 
-	resp, err := libhttp.Do(spanCtx, req, tracer)
+// callOWMService send a GET to url and return the body of a 200 answer
+func callOWMService(ctx context.Context, client *libhttp.Client, url string, tracer trace.Tracer) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	resp, err := client.Do(ctx, req, tracer)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	rwf, err := parseResponse(spanCtx, body, tracer)
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "requestGetWeatherForecastsSuccessfull")
-	return rwf, nil
+	// OWMService answer with the status of the openweathermap error, turn it back into the typed error
+	if resp.StatusCode != http.StatusOK {
+		retryAfter, _ := libhttp.RetryAfter(resp)
+		return nil, openweathermap.NewAPIError(resp.StatusCode, strings.TrimSpace(string(body)), retryAfter)
+	}
+
+	return body, nil
 }
 
 func parseResponse(ctx context.Context, body []byte, tracer trace.Tracer) (*RequestWeatherForecast, error) {
 	rwf := &RequestWeatherForecast{}
 
-	spanCtx, span := tracer.Start(ctx, "call_parseResponse", trace.WithAttributes(attribute.Key("parseResponse").String("parse GetWeatherForecast Response")))
+	_, span := tracer.Start(ctx, "call_parseResponse", trace.WithAttributes(attribute.Key("parseResponse").String("parse GetWeatherForecast Response")))
 	defer span.End()
 
 	err := json.Unmarshal(body, rwf)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 
-	trace.WithSpanKind(trace.SpanKindInternal)
-	trace.SpanFromContext(spanCtx).SetStatus(codes.Ok, "requestparseResponseSuccessfull")
+	span.SetStatus(codes.Ok, "requestparseResponseSuccessfull")
 	return rwf, nil
 
 }
@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envPrefix = "TRACER_"
+
+	endpointEnv    = "ENDPOINT"
+	headersEnv     = "HEADERS"
+	compressionEnv = "COMPRESSION"
+	urlPathEnv     = "URL_PATH"
+	blockingEnv    = "GRPC_BLOCKING"
+	dialTimeoutEnv = "DIAL_TIMEOUT"
+
+	defaultDialTimeout = 10 * time.Second
+)
+
+// exporterEnv read settings of one exporter kind,
+// TRACER_<KIND>_<KEY> take precedence over the shared TRACER_<KEY>
+type exporterEnv struct {
+	kind string
+}
+
+// get return the trimmed value and the name of the variable it was read from
+func (e exporterEnv) get(key string) (string, string) {
+	name := envPrefix + strings.ToUpper(e.kind) + "_" + key
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v, name
+	}
+
+	name = envPrefix + key
+	return strings.TrimSpace(os.Getenv(name)), name
+}
+
+// exporterConfig hold exporter settings which are not part of the InitTracer arguments
+type exporterConfig struct {
+	endpoint     string
+	otlpEndpoint string
+	insecure     bool
+	tlsConfig    *tls.Config
+	urlPath      string
+	headers      map[string]string
+	gzip         bool
+	blocking     bool
+	dialTimeout  time.Duration
+}
+
+func exporterConfigFromEnv(kind string, endpoint string) (exporterConfig, error) {
+	env := exporterEnv{kind: kind}
+
+	// the InitTracer endpoint is used unless the kind has its own
+	if v, name := env.get(endpointEnv); v != "" && name != envPrefix+endpointEnv {
+		endpoint = v
+	}
+
+	cfg := exporterConfig{
+		endpoint:     endpoint,
+		otlpEndpoint: endpoint,
+		insecure:     true,
+		dialTimeout:  defaultDialTimeout,
+	}
+
+	// otlp endpoint may be given as an URL, e.g. https://collector:4318/v1/traces
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid tracer endpoint %q: %w", endpoint, err)
+		}
+		cfg.otlpEndpoint = u.Host
+		cfg.insecure = u.Scheme != "https"
+		if u.Path != "" && u.Path != "/" {
+			cfg.urlPath = u.Path
+		}
+	}
+
+	tlsCfg, err := tlsConfigFromEnv(env)
+	if err != nil {
+		return cfg, err
+	}
+	if tlsCfg != nil {
+		cfg.insecure = false
+		cfg.tlsConfig = tlsCfg
+	} else if !cfg.insecure {
+		// https endpoint without TLS options, verify against system roots
+		cfg.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if v, _ := env.get(urlPathEnv); v != "" {
+		cfg.urlPath = v
+	}
+
+	headers, err := parseHeaders(env.get(headersEnv))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.headers = headers
+
+	switch v, name := env.get(compressionEnv); strings.ToLower(v) {
+	case "", "none":
+	case "gzip":
+		cfg.gzip = true
+	default:
+		return cfg, fmt.Errorf("unrecognized %s %q", name, v)
+	}
+
+	if v, name := env.get(blockingEnv); v != "" {
+		blocking, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s %q: %w", name, v, err)
+		}
+		cfg.blocking = blocking
+	}
+
+	if v, name := env.get(dialTimeoutEnv); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s %q: %w", name, v, err)
+		}
+		if timeout <= 0 {
+			return cfg, fmt.Errorf("invalid %s %q: timeout must be positive", name, v)
+		}
+		cfg.dialTimeout = timeout
+	}
+
+	return cfg, nil
+}
+
+// parseHeaders parse comma separated key=value pairs read from variable name
+func parseHeaders(value string, name string) (map[string]string, error) {
+	headers := map[string]string{}
+	if strings.TrimSpace(value) == "" {
+		return headers, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q, expected key=value", name, pair)
+		}
+
+		key, err := url.QueryUnescape(strings.TrimSpace(kv[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", name, pair, err)
+		}
+		val, err := url.QueryUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", name, pair, err)
+		}
+		headers[key] = val
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"weather/lib/redact"
+	"weather/lib/route"
+)
+
+const (
+	instrumentationName = "weather/lib/tracing"
+
+	// routeBaggageKey carry the route which started the request to downstream services
+	routeBaggageKey = "FunctionRoute"
+)
+
+// Middleware start one SERVER span per request, child of the span propagated by the caller
+// and named by the chi route pattern. Handlers get the span with trace.SpanFromContext(r.Context())
+func Middleware(next http.Handler) http.Handler {
+	tracer := otel.GetTracerProvider().Tracer(instrumentationName)
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		// keep the whole extracted context, the B3 propagator store there whether the caller deferred sampling
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		attrs := append(
+			semconv.HTTPServerAttributesFromHTTPRequest("", "", r),
+			semconv.NetAttributesFromHTTPRequest("tcp", r)...,
+		)
+		routePattern := route.Pattern(r)
+
+		receivedBaggage := baggage.FromContext(ctx)
+
+		if member, err := baggage.NewMember(routeBaggageKey, routePattern); err == nil {
+			if b, err := receivedBaggage.SetMember(member); err == nil {
+				receivedBaggage = b
+			}
+		}
+
+		ctx = baggage.ContextWithBaggage(ctx, receivedBaggage)
+
+		ctx, span := tracer.Start(
+			ctx,
+			routePattern,
+			trace.WithAttributes(redact.Attributes(attrs)...),
+			trace.WithAttributes(semconv.HTTPRouteKey.String(routePattern)),
+			trace.WithSpanKind(trace.SpanKindServer),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				span.RecordError(fmt.Errorf("panic: %v", rec))
+				span.SetStatus(codes.Error, "panic")
+				panic(rec)
+			}
+		}()
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+	return http.HandlerFunc(fn)
+}
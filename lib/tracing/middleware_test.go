@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareServerSpan(t *testing.T) {
+	recorder := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/forecast/{city}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "city") == "nowhere" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/forecast/london", http.StatusOK},
+		{"/forecast/nowhere", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			recorder.Reset()
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			spans := recorder.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+
+			if span.SpanKind != trace.SpanKindServer {
+				t.Errorf("kind = %v, want %v", span.SpanKind, trace.SpanKindServer)
+			}
+			if span.Name != "/forecast/{city}" {
+				t.Errorf("name = %q, want the route pattern", span.Name)
+			}
+			attrs := attributes(span.Attributes)
+			if got := attrs[semconv.HTTPRouteKey].AsString(); got != "/forecast/{city}" {
+				t.Errorf("%s = %q, want /forecast/{city}", semconv.HTTPRouteKey, got)
+			}
+			if got := attrs[semconv.HTTPStatusCodeKey].AsInt64(); got != int64(tt.status) {
+				t.Errorf("%s = %d, want %d", semconv.HTTPStatusCodeKey, got, tt.status)
+			}
+			if got := attrs[semconv.HTTPMethodKey].AsString(); got != http.MethodGet {
+				t.Errorf("%s = %q, want GET", semconv.HTTPMethodKey, got)
+			}
+		})
+	}
+}
+
+func attributes(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
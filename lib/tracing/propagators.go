@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	propagatorsEnv = "OTEL_PROPAGATORS"
+
+	defaultPropagators = "tracecontext,baggage"
+)
+
+// propagatorsFromEnv build the composite propagator listed in OTEL_PROPAGATORS
+func propagatorsFromEnv() (propagation.TextMapPropagator, error) {
+	names := strings.TrimSpace(os.Getenv(propagatorsEnv))
+	if names == "" {
+		names = defaultPropagators
+	}
+
+	return newPropagator(names)
+}
+
+func newPropagator(names string) (propagation.TextMapPropagator, error) {
+	var propagators []propagation.TextMapPropagator
+	seen := map[string]bool{}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, deferredB3{b3.New(b3.WithInjectEncoding(b3.B3SingleHeader))})
+		case "b3multi":
+			propagators = append(propagators, deferredB3{b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader))})
+		case "jaeger":
+			propagators = append(propagators, jaeger.Jaeger{})
+		case "none":
+		default:
+			return nil, fmt.Errorf("unrecognized %s %q", propagatorsEnv, name)
+		}
+	}
+
+	if seen["none"] && len(propagators) > 0 {
+		return nil, fmt.Errorf("%s none can not be combined with other propagators", propagatorsEnv)
+	}
+
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+type samplingDeferredKey struct{}
+
+// deferredB3 mark the context when the B3 caller left the sampling decision to us,
+// the extracted parent is then unsampled but must not be taken as a refusal, see deferredParentBased
+type deferredB3 struct {
+	propagation.TextMapPropagator
+}
+
+func (p deferredB3) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	ctx = p.TextMapPropagator.Extract(ctx, carrier)
+	if trace.SpanContextFromContext(ctx).IsValid() && b3SamplingDeferred(carrier) {
+		ctx = context.WithValue(ctx, samplingDeferredKey{}, true)
+	}
+	return ctx
+}
+
+// b3SamplingDeferred report whether the B3 headers carry ids without sampling state
+func b3SamplingDeferred(carrier propagation.TextMapCarrier) bool {
+	if single := carrier.Get("b3"); single != "" {
+		return len(strings.Split(single, "-")) == 2
+	}
+	return carrier.Get("x-b3-traceid") != "" && carrier.Get("x-b3-sampled") == "" && carrier.Get("x-b3-flags") == ""
+}
+
+func samplingDeferred(ctx context.Context) bool {
+	deferred, _ := ctx.Value(samplingDeferredKey{}).(bool)
+	return deferred
+}
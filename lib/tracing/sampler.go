@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	samplerEnv    = "OTEL_TRACES_SAMPLER"
+	samplerArgEnv = "OTEL_TRACES_SAMPLER_ARG"
+
+	defaultSampler = "parentbased_always_on"
+)
+
+// samplerFromEnv build the sampler described by OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG
+func samplerFromEnv() (sdktrace.Sampler, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv(samplerEnv)))
+	if name == "" {
+		name = defaultSampler
+	}
+	arg := strings.TrimSpace(os.Getenv(samplerArgEnv))
+
+	return newSampler(name, arg)
+}
+
+func newSampler(name string, arg string) (sdktrace.Sampler, error) {
+	switch name {
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		ratio, err := parseSamplerRatio(arg)
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case "parentbased_always_on":
+		return newDeferredParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return newDeferredParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		ratio, err := parseSamplerRatio(arg)
+		if err != nil {
+			return nil, err
+		}
+		return newDeferredParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	}
+
+	return nil, fmt.Errorf("unrecognized %s %q", samplerEnv, name)
+}
+
+// parseSamplerRatio parse sampling ratio, an empty argument sample everything
+func parseSamplerRatio(arg string) (float64, error) {
+	if arg == "" {
+		return 1.0, nil
+	}
+
+	ratio, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", samplerArgEnv, arg, err)
+	}
+	if ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("invalid %s %q: ratio must be between 0 and 1", samplerArgEnv, arg)
+	}
+
+	return ratio, nil
+}
+
+// deferredParentBased follow the parent like sdktrace.ParentBased, except for a remote parent
+// whose caller deferred the sampling decision, for which root decide as for a new trace
+type deferredParentBased struct {
+	sdktrace.Sampler
+	root sdktrace.Sampler
+}
+
+func newDeferredParentBased(root sdktrace.Sampler) sdktrace.Sampler {
+	return deferredParentBased{Sampler: sdktrace.ParentBased(root), root: root}
+}
+
+func (s deferredParentBased) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := trace.SpanContextFromContext(p.ParentContext)
+	if parent.IsRemote() && !parent.IsSampled() && samplingDeferred(p.ParentContext) {
+		return s.root.ShouldSample(p)
+	}
+	return s.Sampler.ShouldSample(p)
+}
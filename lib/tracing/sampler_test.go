@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestParentBasedSamplerWithB3(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		sampled bool
+	}{
+		{"single header deferred", map[string]string{"b3": traceID + "-" + spanID}, true},
+		{"single header sampled", map[string]string{"b3": traceID + "-" + spanID + "-1"}, true},
+		{"single header not sampled", map[string]string{"b3": traceID + "-" + spanID + "-0"}, false},
+		{"multi header deferred", map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID}, true},
+		{"multi header not sampled", map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID, "X-B3-Sampled": "0"}, false},
+	}
+
+	propagator, err := newPropagator("b3,b3multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampler, err := newSampler("parentbased_always_on", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler))
+	tracer := provider.Tracer("test")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+
+			ctx := propagator.Extract(context.Background(), propagation.HeaderCarrier(header))
+			_, span := tracer.Start(ctx, "server")
+			defer span.End()
+
+			sc := span.SpanContext()
+			if sc.TraceID().String() != traceID {
+				t.Errorf("trace id = %s, want %s", sc.TraceID(), traceID)
+			}
+			if sc.IsSampled() != tt.sampled {
+				t.Errorf("sampled = %v, want %v", sc.IsSampled(), tt.sampled)
+			}
+		})
+	}
+}
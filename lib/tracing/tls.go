@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	caFileEnv     = "CA_FILE"
+	certFileEnv   = "CERT_FILE"
+	keyFileEnv    = "KEY_FILE"
+	serverNameEnv = "SERVER_NAME"
+)
+
+// tlsConfigFromEnv build the exporter TLS configuration, nil when no TLS option is set
+func tlsConfigFromEnv(env exporterEnv) (*tls.Config, error) {
+	caFile, caFileName := env.get(caFileEnv)
+	certFile, certFileName := env.get(certFileEnv)
+	keyFile, keyFileName := env.get(keyFileEnv)
+	serverName, _ := env.get(serverNameEnv)
+
+	if caFile == "" && certFile == "" && keyFile == "" && serverName == "" {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		caPEM, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", caFileName, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in %s %q", caFileName, caFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	// client certificate for mTLS, both files must be present
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("%s and %s must be set together", certFileName, keyFileName)
+		}
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
@@ -3,79 +3,222 @@ package tracing
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"weather/lib/logging"
+	"weather/lib/multierr"
 )
 
-func InitTracer(ctx context.Context, kind string, serviceName string, endpoint string) (func(), error) {
-	var exporter sdktrace.SpanExporter
+const shutdownTimeout = 5 * time.Second
+
+// Provider own the tracer provider and exporters installed by InitTracer
+type Provider struct {
+	tracerProvider *sdktrace.TracerProvider
+	exporters      []sdktrace.SpanExporter
+}
+
+// Shutdown flush pending spans and stop every exporter, giving up after shutdownTimeout
+// when ctx has no earlier deadline. All failures are returned together
+func (p *Provider) Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+
+	var errs multierr.Errors
+	if err := p.tracerProvider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to shutdown provider: %w", err))
+	}
+	for _, exporter := range p.exporters {
+		if err := exporter.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop exporter: %w", err))
+		}
+	}
+
+	return errs.Err()
+}
 
-	log.Printf("Endpoint %s", endpoint)
+func InitTracer(ctx context.Context, kind string, serviceName string, endpoint string) (*Provider, error) {
+	logging.Info(ctx, "init tracer", logging.Fields{"kind": kind, "endpoint": endpoint})
 
 	traceLabels := []attribute.KeyValue{
 		attribute.String("service.name", serviceName),
 		attribute.String("Host", os.Getenv("HOSTNAME")),
 	}
+	sampler, err := samplerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	propagator, err := propagatorsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	processDetector, err := resource.New(ctx, resource.WithOSType(), resource.WithProcess(), resource.WithProcessExecutableName())
 	if err != nil {
 		return nil, err
 	}
 
+	kinds, err := parseKinds(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(processDetector),
+		sdktrace.WithResource(resource.NewSchemaless(traceLabels...)),
+	}
+
+	// one batch span processor per exporter
+	var exporters []sdktrace.SpanExporter
+	for _, k := range kinds {
+		exporter, err := newExporter(ctx, k, endpoint)
+		if err != nil {
+			for _, created := range exporters {
+				_ = created.Shutdown(ctx)
+			}
+			return nil, fmt.Errorf("%s exporter: %w", k, err)
+		}
+		exporters = append(exporters, exporter)
+		providerOptions = append(providerOptions, sdktrace.WithBatcher(exporter))
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(providerOptions...)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagator)
+
+	return &Provider{
+		tracerProvider: tracerProvider,
+		exporters:      exporters,
+	}, nil
+}
+
+// parseKinds split comma separated exporter kinds, e.g. "oteltrace,stdouttrace"
+func parseKinds(kind string) ([]string, error) {
+	var kinds []string
+	seen := map[string]bool{}
+
+	for _, k := range strings.Split(kind, ",") {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		kinds = append(kinds, k)
+	}
+
+	if len(kinds) == 0 {
+		return nil, errors.New("no tracer kind given")
+	}
+
+	return kinds, nil
+}
+
+func newExporter(ctx context.Context, kind string, endpoint string) (sdktrace.SpanExporter, error) {
+	cfg, err := exporterConfigFromEnv(kind, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
 	if strings.EqualFold(kind, "stdouttrace") {
 		exporterStdout, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
 		if err != nil {
 			return nil, err
 		}
-		exporter = sdktrace.SpanExporter(exporterStdout)
+		return sdktrace.SpanExporter(exporterStdout), nil
 	} else if strings.EqualFold(kind, "jaeger") {
-		exporterJaeger, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(endpoint)))
+		collectorOpts := []jaeger.CollectorEndpointOption{jaeger.WithEndpoint(cfg.endpoint)}
+		if cfg.tlsConfig != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = cfg.tlsConfig
+			collectorOpts = append(collectorOpts, jaeger.WithHTTPClient(&http.Client{Transport: transport}))
+		}
+
+		exporterJaeger, err := jaeger.New(jaeger.WithCollectorEndpoint(collectorOpts...))
 		if err != nil {
 			return nil, err
 		}
-		exporter = sdktrace.SpanExporter(exporterJaeger)
+		return sdktrace.SpanExporter(exporterJaeger), nil
 	} else if strings.EqualFold(kind, "oteltrace") {
-		exporterOtel, err := otlptracegrpc.New(
-			ctx,
-			otlptracegrpc.WithInsecure(),
-			otlptracegrpc.WithEndpoint(endpoint),
-			otlptracegrpc.WithDialOption(grpc.WithBlock()),
-		)
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(cfg.otlpEndpoint),
+			otlptracegrpc.WithHeaders(cfg.headers),
+		}
+		if cfg.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		} else {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(cfg.tlsConfig)))
+		}
+		if cfg.gzip {
+			opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+		}
+
+		// without blocking the connection is established in background,
+		// when blocking wait for the collector at most dialTimeout
+		dialCtx := ctx
+		if cfg.blocking {
+			var cancel context.CancelFunc
+			dialCtx, cancel = context.WithTimeout(ctx, cfg.dialTimeout)
+			defer cancel()
+			opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithBlock()))
+		}
+
+		exporterOtel, err := otlptracegrpc.New(dialCtx, opts...)
 		if err != nil {
 			return nil, err
 		}
-		exporter = sdktrace.SpanExporter(exporterOtel)
-	} else {
-		return nil, errors.New("unrecognized tracer kind")
-	}
+		return sdktrace.SpanExporter(exporterOtel), nil
+	} else if strings.EqualFold(kind, "otlphttp") {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(cfg.otlpEndpoint),
+			otlptracehttp.WithHeaders(cfg.headers),
+		}
+		if cfg.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(cfg.tlsConfig))
+		}
+		if cfg.urlPath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(cfg.urlPath))
+		}
+		if cfg.gzip {
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		}
 
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithResource(processDetector),
-		sdktrace.WithResource(resource.NewSchemaless(traceLabels...)),
-	)
-	otel.SetTracerProvider(tracerProvider)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+		exporterOtlpHTTP, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.SpanExporter(exporterOtlpHTTP), nil
+	}
 
-	return func() {
-		handleErr(tracerProvider.Shutdown(ctx), "failed to shutdown provider")
-		handleErr(exporter.Shutdown(ctx), "failed to stop exporter")
-	}, nil
+	return nil, fmt.Errorf("unrecognized tracer kind %q", kind)
 }
 
-func handleErr(err error, message string) {
-	if err != nil {
-		log.Fatalf("%s: %v", message, err)
+// RecordError record err on span and mark the span failed, err is returned for convenience
+func RecordError(span trace.Span, err error) error {
+	if err == nil {
+		return nil
 	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
 }
@@ -3,18 +3,23 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
-
+	"os/signal"
+	"syscall"
+	"time"
+
+	"weather/lib/auth"
+	libhttp "weather/lib/http"
+	"weather/lib/logging"
+	"weather/lib/metrics"
+	"weather/lib/multierr"
 	"weather/lib/owmclient"
+	"weather/lib/realip"
+	"weather/lib/server"
 	"weather/lib/tracing"
 
-	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/go-chi/chi"
@@ -25,124 +30,170 @@ import (
 const svcName = "OWMService"
 
 func pingReceiver(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(svcName))
+}
 
-	tracer := otel.GetTracerProvider().Tracer("ping_receiver_route on OWMService")
-	attrs, _, receivedCtx := otelhttptrace.Extract(r.Context(), r)
-
-	spanLabels := []attribute.KeyValue{
-		attribute.String("URI", r.RequestURI),
-		attribute.String("METHOD", r.Method),
-		attribute.String("PROTO", r.Proto),
+func getWeatherByCity(provider owmclient.WeatherProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tracer := otel.GetTracerProvider().Tracer("getWeatherByCity_route on OWMservice")
+
+		city := chi.URLParam(r, "city")
+		cityWeather, err := owmclient.GetOwmForecastByCity(ctx, provider, city, tracer)
+		if err != nil {
+			logging.Error(ctx, "failed to get weather", logging.Fields{"error": err, "city": city})
+			trace.SpanFromContext(ctx).RecordError(err)
+			server.WriteError(w, err)
+			return
+		}
+
+		render.JSON(w, r, cityWeather)
 	}
+}
 
-	baggageGetWeatherByCity, _ := baggage.NewMember(string("FunctionRoute"), "pingReceiverRoute")
-	baggageContents, err := baggage.New(baggageGetWeatherByCity)
-	if err != nil {
-		log.Fatalf("Error occurred: %s", err)
+func getForecastByCity(provider owmclient.WeatherProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tracer := otel.GetTracerProvider().Tracer("getForecastByCity_route on OWMservice")
+
+		city := chi.URLParam(r, "city")
+		cityForecast, err := owmclient.GetOwmFiveDayForecastByCity(ctx, provider, city, tracer)
+		if err != nil {
+			logging.Error(ctx, "failed to get forecast", logging.Fields{"error": err, "city": city})
+			trace.SpanFromContext(ctx).RecordError(err)
+			server.WriteError(w, err)
+			return
+		}
+
+		render.JSON(w, r, cityForecast)
 	}
+}
 
-	_, span := tracer.Start(
-		trace.ContextWithRemoteSpanContext(r.Context(), receivedCtx),
-		"ping_receiver_route has been invoked",
-		trace.WithAttributes(attrs...),
-		trace.WithAttributes(spanLabels...),
-		trace.WithSpanKind(trace.SpanKindProducer),
-	)
-	defer span.End()
+// shutdownTimeout bound the wait for in flight requests once the service is asked to stop
+const shutdownTimeout = 10 * time.Second
 
-	span.SetAttributes(attribute.Key("baggage").String(baggageContents.Member("FunctionRoute").Value()))
-	span.SetStatus(codes.Ok, "requestPingReceiverRouteSuccessfull")
-	w.Write([]byte(fmt.Sprintf("%s", svcName)))
+func main() {
+	logging.Init(svcName)
 
+	if err := run(); err != nil {
+		logging.Fatal(context.Background(), "service failed", logging.Fields{"error": err})
+	}
 }
 
-func getWeatherByCity(w http.ResponseWriter, r *http.Request) {
+// run serve until SIGINT or SIGTERM, errors are returned so deferred flushes always run
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	tracer := otel.GetTracerProvider().Tracer("getWeatherByCity_route on OWMservice")
-	attrs, _, receivedCtx := otelhttptrace.Extract(r.Context(), r)
-
-	spanLabels := []attribute.KeyValue{
-		attribute.String("URI", r.RequestURI),
-		attribute.String("METHOD", r.Method),
-		attribute.String("PROTO", r.Proto),
+	port := "8082"
+	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
+		port = fromEnv
 	}
 
-	baggageGetWeatherByCity, _ := baggage.NewMember(string("FunctionRoute"), "getWeatherByCity()")
-	baggageContents, err := baggage.New(baggageGetWeatherByCity)
-	if err != nil {
-		log.Fatalf("Error occurred: %s", err)
+	metricsPort := "9092"
+	if fromEnv := os.Getenv("METRICS_PORT"); fromEnv != "" {
+		metricsPort = fromEnv
 	}
 
-	r = r.WithContext(baggage.ContextWithBaggage(r.Context(), baggageContents))
-
-	spanCtx, span := tracer.Start(
-		trace.ContextWithRemoteSpanContext(r.Context(), receivedCtx),
-		"getWeatherByCity_route has been invoked",
-		trace.WithAttributes(attrs...),
-		trace.WithAttributes(spanLabels...),
-		trace.WithSpanKind(trace.SpanKindProducer),
-	)
-	defer span.End()
+	tracerKind := "oteltrace"
+	if fromEnv := os.Getenv("TRACER_KIND"); fromEnv != "" {
+		tracerKind = fromEnv
+	}
 
-	city := chi.URLParam(r, "city")
-	cityWeather, err := owmclient.GetOwmForecastByCity(spanCtx, city, tracer)
+	tracerEndpoint := os.Getenv("TRACER_ENDPOINT")
+	tracer, err := tracing.InitTracer(ctx, tracerKind, svcName, tracerEndpoint)
 	if err != nil {
-		log.Printf("%s", err)
-		w.WriteHeader(500)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
+		return fmt.Errorf("failed to init tracer: %w", err)
 	}
-
-	span.SetAttributes(attribute.Key("baggage").String(baggageContents.Member("FunctionRoute").Value()))
-	span.SetStatus(codes.Ok, "requestGetWeatherByCityRouteSuccessfull")
-	render.JSON(w, r, cityWeather)
-}
-
-func httpTraceWrapper(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		t := otel.GetTracerProvider().Tracer("http-root-tracer")
-		ctx, span := t.Start(r.Context(), r.URL.Path)
-		r = r.WithContext(ctx)
-		h.ServeHTTP(w, r)
-		defer span.End()
+	defer func() {
+		if err := tracer.Shutdown(context.Background()); err != nil {
+			logging.Error(ctx, "failed to shutdown tracer", logging.Fields{"error": err})
+		}
+	}()
+
+	meterKind := "prometheus"
+	if fromEnv := os.Getenv("METER_KIND"); fromEnv != "" {
+		meterKind = fromEnv
 	}
-	return http.HandlerFunc(fn)
-}
 
-func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	meterEndpoint := os.Getenv("METER_ENDPOINT")
+	meter, err := metrics.InitMeter(ctx, meterKind, svcName, meterEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to init meter: %w", err)
+	}
+	defer func() {
+		if err := meter.Shutdown(context.Background()); err != nil {
+			logging.Error(ctx, "failed to shutdown meter", logging.Fields{"error": err})
+		}
+	}()
 
-	port := "8082"
-	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
-		port = fromEnv
+	client, err := libhttp.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to init http client: %w", err)
 	}
 
-	tracerEndpoint := os.Getenv("TRACER_ENDPOINT")
-	tracer, err := tracing.InitTracer(ctx, "oteltrace", svcName, tracerEndpoint)
+	provider, err := owmclient.NewProviderFromEnv(client)
 	if err != nil {
-		log.Fatalf("Error occurred: %s", err)
+		return fmt.Errorf("failed to init weather provider: %w", err)
 	}
-	defer tracer()
 
-	log.Printf("Starting %s", svcName)
+	logging.Info(ctx, "starting service", logging.Fields{"port": port, "metrics_port": metricsPort, "weather_provider": provider.Name()})
+
+	realIP, err := realip.NewFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to init trusted proxies: %w", err)
+	}
 
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(realIP)
+	r.Use(tracing.Middleware)
+	// WeatherService authenticated the client and sent its ID in the baggage
+	r.Use(auth.PropagatedMiddleware)
+	r.Use(logging.Middleware)
 	r.Use(middleware.Recoverer)
+	r.Use(metrics.Middleware)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 
 	r.Get("/ping", pingReceiver)
 	r.Route("/getweather/owm", func(r chi.Router) {
-		r.Get("/{city}", getWeatherByCity)
+		r.Get("/{city}", getWeatherByCity(provider))
+	})
+	r.Route("/getforecast/owm", func(r chi.Router) {
+		r.Get("/{city}", getForecastByCity(provider))
 	})
 
-	errListen := http.ListenAndServe(":"+port, httpTraceWrapper(r))
+	servers := []*http.Server{{Addr: ":" + port, Handler: r}}
+	// the scrape endpoint listen on its own port, which is not routed by the gateway
+	if handler := meter.Handler(); handler != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", handler)
+		servers = append(servers, &http.Server{Addr: ":" + metricsPort, Handler: mux})
+	}
+
+	serveErr := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			serveErr <- srv.ListenAndServe()
+		}(srv)
+	}
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("ListenAndServe failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	logging.Info(ctx, "stopping service", nil)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	if errListen != nil {
-		fmt.Println("ListenAndServe:", err)
+	var errs multierr.Errors
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errs.Err()
 }
@@ -3,163 +3,241 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"weather/lib/auth"
+	libhttp "weather/lib/http"
+	"weather/lib/logging"
+	"weather/lib/metrics"
+	"weather/lib/multierr"
 	"weather/lib/ping"
+	"weather/lib/ratelimit"
+	"weather/lib/realip"
 	"weather/lib/server"
 	"weather/lib/tracing"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
 const svcName = "WeatherService"
 
-func pingCaller(w http.ResponseWriter, r *http.Request) {
-	tracer := otel.GetTracerProvider().Tracer("ping_caller_route on WeatherService")
-	attrs, _, receivedCtx := otelhttptrace.Extract(r.Context(), r)
-
-	spanLabels := []attribute.KeyValue{
-		attribute.String("URI", r.RequestURI),
-		attribute.String("METHOD", r.Method),
-		attribute.String("PROTO", r.Proto),
+func pingCaller(client *libhttp.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tracer := otel.GetTracerProvider().Tracer("ping_caller_route on WeatherService")
+
+		pingServer := owmAddr()
+		response, err := ping.Ping(ctx, client, pingServer, tracer)
+		if err != nil {
+			logging.Error(ctx, "failed to ping", logging.Fields{"error": err, "owm_addr": pingServer})
+			trace.SpanFromContext(ctx).RecordError(err)
+			server.WriteError(w, err)
+			return
+		}
+
+		w.Write([]byte(fmt.Sprintf("%s -> %s", svcName, response)))
 	}
+}
 
-	baggageGetWeatherByCity, _ := baggage.NewMember(string("FunctionRoute"), "pingCallerRoute")
-	baggageContents, err := baggage.New(baggageGetWeatherByCity)
-	if err != nil {
-		log.Fatalf("Error occurred: %s", err)
+func currentWeather(client *libhttp.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tracer := otel.GetTracerProvider().Tracer("currentWeather_route on WeatherService")
+
+		city := chi.URLParam(r, "city")
+		wF, err := server.GetWeatherForecast(ctx, client, owmAddr(), city, tracer)
+		if err != nil {
+			logging.Error(ctx, "failed to get weather", logging.Fields{"error": err, "city": city})
+			trace.SpanFromContext(ctx).RecordError(err)
+			server.WriteError(w, err)
+			return
+		}
+
+		render.JSON(w, r, wF)
 	}
+}
 
-	spanCtx, span := tracer.Start(
-		trace.ContextWithRemoteSpanContext(r.Context(), receivedCtx),
-		"ping_caller_route has been invoked",
-		trace.WithAttributes(attrs...),
-		trace.WithAttributes(spanLabels...),
-		trace.WithSpanKind(trace.SpanKindConsumer),
-	)
-	defer span.End()
-
-	pingServer, ok := os.LookupEnv("OWM_ADDR")
-	if !ok {
-		pingServer = "localhost:8082"
+func weatherForecast(client *libhttp.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tracer := otel.GetTracerProvider().Tracer("weatherForecast_route on WeatherService")
+
+		city := chi.URLParam(r, "city")
+		fD, err := server.GetFiveDayForecast(ctx, client, owmAddr(), city, tracer)
+		if err != nil {
+			logging.Error(ctx, "failed to get weather forecast", logging.Fields{"error": err, "city": city})
+			trace.SpanFromContext(ctx).RecordError(err)
+			server.WriteError(w, err)
+			return
+		}
+
+		render.JSON(w, r, fD)
 	}
+}
 
-	response, err := ping.Ping(spanCtx, pingServer, tracer)
-	if err != nil {
-		log.Println(err)
-		w.WriteHeader(500)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
+// owmAddr return the address of OWMService
+func owmAddr() string {
+	if addr, ok := os.LookupEnv("OWM_ADDR"); ok {
+		return addr
 	}
-
-	span.SetAttributes(attribute.Key("baggage").String(baggageContents.Member("FunctionRoute").Value()))
-	span.SetStatus(codes.Ok, "requestPingCallerRouteSuccessfull")
-	w.Write([]byte(fmt.Sprintf("%s -> %s", svcName, response)))
-
+	return "localhost:8082"
 }
 
-func weatherForecast(w http.ResponseWriter, r *http.Request) {
-	tracer := otel.GetTracerProvider().Tracer("weatherForecast_route on WeatherService")
-	attrs, _, receivedCtx := otelhttptrace.Extract(r.Context(), r)
+// shutdownTimeout bound the wait for in flight requests once the service is asked to stop
+const shutdownTimeout = 10 * time.Second
 
-	spanLabels := []attribute.KeyValue{
-		attribute.String("URI", r.RequestURI),
-		attribute.String("METHOD", r.Method),
-		attribute.String("PROTO", r.Proto),
-	}
+func main() {
+	logging.Init(svcName)
 
-	baggageWeatherForecast, _ := baggage.NewMember(string("FunctionRoute"), "weatherForecast()")
-	baggageContents, err := baggage.New(baggageWeatherForecast)
-	if err != nil {
-		log.Fatalf("Error occurred: %s", err)
+	if err := run(); err != nil {
+		logging.Fatal(context.Background(), "service failed", logging.Fields{"error": err})
 	}
+}
 
-	r = r.WithContext(baggage.ContextWithBaggage(r.Context(), baggageContents))
+// run serve until SIGINT or SIGTERM, errors are returned so deferred flushes always run
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	spanCtx, span := tracer.Start(
-		trace.ContextWithRemoteSpanContext(r.Context(), receivedCtx),
-		"weatherForecast_route has been invoked",
-		trace.WithAttributes(attrs...),
-		trace.WithAttributes(spanLabels...),
-		trace.WithSpanKind(trace.SpanKindConsumer),
-	)
+	port := "8080"
+	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
+		port = fromEnv
+	}
 
-	defer span.End()
+	metricsPort := "9090"
+	if fromEnv := os.Getenv("METRICS_PORT"); fromEnv != "" {
+		metricsPort = fromEnv
+	}
 
-	owmAddr, ok := os.LookupEnv("OWM_ADDR")
-	if !ok {
-		owmAddr = "localhost:8082"
+	tracerKind := "oteltrace"
+	if fromEnv := os.Getenv("TRACER_KIND"); fromEnv != "" {
+		tracerKind = fromEnv
 	}
 
-	city := chi.URLParam(r, "city")
-	wF, err := server.GetWeatherForecast(spanCtx, owmAddr, city, tracer)
+	tracerEndpoint := os.Getenv("TRACER_ENDPOINT")
+	tracer, err := tracing.InitTracer(ctx, tracerKind, svcName, tracerEndpoint)
 	if err != nil {
-		w.WriteHeader(500)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
+		return fmt.Errorf("failed to init tracer: %w", err)
+	}
+	defer func() {
+		if err := tracer.Shutdown(context.Background()); err != nil {
+			logging.Error(ctx, "failed to shutdown tracer", logging.Fields{"error": err})
+		}
+	}()
+
+	meterKind := "prometheus"
+	if fromEnv := os.Getenv("METER_KIND"); fromEnv != "" {
+		meterKind = fromEnv
 	}
 
-	span.SetStatus(codes.Ok, "requestWeatherForecastRouteSuccessfull")
-	span.SetAttributes(attribute.Key("baggage").String(baggageContents.Member("FunctionRoute").Value()))
-	render.JSON(w, r, wF)
-
-}
+	meterEndpoint := os.Getenv("METER_ENDPOINT")
+	meter, err := metrics.InitMeter(ctx, meterKind, svcName, meterEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to init meter: %w", err)
+	}
+	defer func() {
+		if err := meter.Shutdown(context.Background()); err != nil {
+			logging.Error(ctx, "failed to shutdown meter", logging.Fields{"error": err})
+		}
+	}()
 
-func httpTraceWrapper(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		t := otel.GetTracerProvider().Tracer("http-root-tracer")
-		ctx, span := t.Start(r.Context(), r.URL.Path)
-		r = r.WithContext(ctx)
-		h.ServeHTTP(w, r)
-		span.End()
+	client, err := libhttp.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to init http client: %w", err)
 	}
-	return http.HandlerFunc(fn)
-}
 
-func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	limits, err := ratelimit.ConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to init rate limiter: %w", err)
+	}
+	limiter := ratelimit.New(limits)
 
-	port := "8080"
-	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
-		port = fromEnv
+	failureLimits, err := ratelimit.AuthFailureConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to init authentication failures limiter: %w", err)
 	}
+	failureLimiter := ratelimit.New(failureLimits)
 
-	tracerEndpoint := os.Getenv("TRACER_ENDPOINT")
-	tracer, err := tracing.InitTracer(ctx, "oteltrace", svcName, tracerEndpoint)
+	authenticator, err := auth.NewFromEnv()
 	if err != nil {
-		log.Fatalf("Error occurred: %s", err)
+		return fmt.Errorf("failed to init authentication: %w", err)
+	}
+	if !authenticator.Enabled() {
+		logging.Warn(ctx, "no api key nor token secret configured, authentication disabled", nil)
 	}
-	defer tracer()
 
-	log.Printf("Starting %s", svcName)
+	logging.Info(ctx, "starting service", logging.Fields{"port": port, "metrics_port": metricsPort})
+
+	realIP, err := realip.NewFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to init trusted proxies: %w", err)
+	}
 
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(realIP)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
 	r.Use(middleware.Recoverer)
+	r.Use(metrics.Middleware)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 
-	r.Get("/ping", pingCaller)
-	r.Route("/forecast", func(r chi.Router) {
-		r.Get("/{city}", weatherForecast)
+	// /ping is the health check of the probes, which send no credentials
+	r.Get("/ping", pingCaller(client))
+
+	r.Group(func(r chi.Router) {
+		// addresses guessing credentials are throttled before their attempts are checked
+		r.Use(failureLimiter.FailuresMiddleware)
+		r.Use(authenticator.Middleware)
+		r.Use(limiter.Middleware)
+
+		r.Route("/weather", func(r chi.Router) {
+			r.Get("/{city}", currentWeather(client))
+		})
+		r.Route("/forecast", func(r chi.Router) {
+			r.Get("/{city}", weatherForecast(client))
+		})
 	})
 
-	errListen := http.ListenAndServe(":"+port, httpTraceWrapper(r))
+	servers := []*http.Server{{Addr: ":" + port, Handler: r}}
+	// the scrape endpoint listen on its own port, which is not routed by the gateway
+	if handler := meter.Handler(); handler != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", handler)
+		servers = append(servers, &http.Server{Addr: ":" + metricsPort, Handler: mux})
+	}
 
-	if errListen != nil {
-		fmt.Println("ListenAndServe:", err)
+	serveErr := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			serveErr <- srv.ListenAndServe()
+		}(srv)
 	}
 
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("ListenAndServe failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	logging.Info(ctx, "stopping service", nil)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	var errs multierr.Errors
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.Err()
 }